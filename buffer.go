@@ -0,0 +1,100 @@
+package memoryscanner
+
+import (
+	"bytes"
+	"context"
+	"sync"
+)
+
+// matchSlabSize is the size of the blocks that match data is copied into
+const matchSlabSize = 64 << 10
+
+// readBufferPool holds read buffers for reuse across chunks, regions and scans
+var readBufferPool sync.Pool
+
+// getReadBuffer returns a buffer of size bytes, reusing a pooled one if it is large enough
+func getReadBuffer(size int) []byte {
+	if pooled, ok := readBufferPool.Get().(*[]byte); ok && cap(*pooled) >= size {
+		return (*pooled)[:size]
+	}
+	return make([]byte, size)
+}
+
+// putReadBuffer returns a buffer to the pool
+func putReadBuffer(buffer []byte) {
+	readBufferPool.Put(&buffer)
+}
+
+// scanBudget hands out the read buffers of a scan, limiting how many are in
+// use at once so the scan stays within ScanOptions.MemoryBudget
+type scanBudget struct {
+	// chunkSize is the number of bytes each chunk read contributes, excluding overlap
+	chunkSize uint64
+	// bufferSize is the size of every read buffer, the chunk size plus overlap and context
+	bufferSize int
+	tokens     chan struct{}
+}
+
+// newScanBudget sizes the read buffers for a scan with the given number of
+// concurrent readers and bytes read beside each chunk
+func newScanBudget(opts ScanOptions, readers int, overlap int) *scanBudget {
+	chunkSize := uint64(opts.ChunkSize)
+	if opts.ChunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	count := readers
+	if opts.MemoryBudget > 0 {
+		budget := uint64(opts.MemoryBudget)
+		// Shrink the chunks until a single buffer fits, but never below a page
+		if chunkSize+uint64(overlap) > budget {
+			chunkSize = max(budget-min(budget, uint64(overlap)), pageSize)
+		}
+		count = min(max(int(budget/(chunkSize+uint64(overlap))), 1), readers)
+	}
+
+	return &scanBudget{
+		chunkSize:  chunkSize,
+		bufferSize: int(chunkSize) + overlap,
+		tokens:     make(chan struct{}, count),
+	}
+}
+
+// acquire waits until the budget allows another buffer and returns it
+func (b *scanBudget) acquire(ctx context.Context) ([]byte, error) {
+	select {
+	case b.tokens <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return getReadBuffer(b.bufferSize), nil
+}
+
+// release returns a buffer obtained from acquire
+func (b *scanBudget) release(buffer []byte) {
+	putReadBuffer(buffer)
+	<-b.tokens
+}
+
+// matchArena copies match data into shared slabs, so a scan allocates once per
+// slab instead of once per match. Copies are never overwritten, so they may be
+// retained; a retained copy keeps its slab alive.
+type matchArena struct {
+	free []byte
+}
+
+// copy returns a copy of data
+func (a *matchArena) copy(data []byte) []byte {
+	if len(data) > len(a.free) {
+		// Large matches get their own allocation instead of wasting a slab
+		if len(data) > matchSlabSize/4 {
+			return bytes.Clone(data)
+		}
+		a.free = make([]byte, matchSlabSize)
+	}
+
+	n := copy(a.free, data)
+	result := a.free[:n:n]
+	a.free = a.free[n:]
+	return result
+}
@@ -3,10 +3,15 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"syscall"
 	"time"
@@ -18,7 +23,53 @@ const (
 	logFilePrefix = "wechatmemorysearch"
 )
 
+// scanConfig 保存每个目标共用的扫描设置
+type scanConfig struct {
+	pattern      string
+	maxLength    int
+	terminator   memoryscanner.Terminator
+	workers      int
+	memoryBudget int
+}
+
+// scanTarget 表示一个扫描目标（进程或内存文件）
+type scanTarget struct {
+	name string
+	open func(ctx context.Context) (*memoryscanner.Scanner, error)
+}
+
 func main() {
+	filePath := flag.String("file", "", "扫描内存文件或目录（原始内存、ELF core、minidump、快照），而不是搜索进程")
+	saveDir := flag.String("save", "", "先将进程内存保存为快照文件到该目录，再扫描快照")
+	baseAddress := flag.String("base", "0x0", "原始内存文件的基址（十六进制）")
+	workers := flag.Int("workers", runtime.NumCPU(), "并行扫描的区域数")
+	budgetMB := flag.Int("budget", 0, "扫描读取缓冲区的内存上限（MB），0 表示不限制")
+	terminatorName := flag.String("term", "print", "匹配内容的结束规则: print（不可打印字符）、nul、utf16、none（固定长度）")
+
+	showMap := flag.Bool("map", false, "只打印目标的内存区域表，不进行搜索")
+	flag.Parse()
+
+	base, err := memoryscanner.ParseAddress(*baseAddress)
+	if err != nil {
+		fmt.Printf("基址无效: %v\n", err)
+		return
+	}
+
+	terminator, ok := parseTerminator(*terminatorName)
+	if !ok {
+		fmt.Printf("未知的结束规则: %s\n", *terminatorName)
+		return
+	}
+
+	if *showMap {
+		for _, target := range buildTargets(*filePath, base, "") {
+			if err := printMemoryMap(context.Background(), target); err != nil {
+				fmt.Printf("读取%s的内存区域失败: %s\n", target.name, describeScanError(err))
+			}
+		}
+		return
+	}
+
 	defer func() {
 		// 获取用户输入
 		reader := bufio.NewReader(os.Stdin)
@@ -80,37 +131,10 @@ func main() {
 		log.Printf("搜索字符串: '%s' (长度: %d)", searchStr, searchLength)
 	}
 
-	// 搜索所有 WeChatAppEx.exe 进程
-	fmt.Println("正在搜索 WeChatAppEx.exe 进程...")
-	wechatAppExPids, err := memoryscanner.FindProcessesByName("WeChatAppEx.exe")
-	if err != nil {
-		// 静默处理，不报错，返回空列表
-		wechatAppExPids = []uint32{}
-	}
-
-	// 搜索所有 WechatBrowser.exe 进程
-	fmt.Println("正在搜索 WechatBrowser.exe 进程...")
-	wechatBrowserPids, err := memoryscanner.FindProcessesByName("WechatBrowser.exe")
-	if err != nil {
-		// 静默处理，不报错，返回空列表
-		wechatBrowserPids = []uint32{}
-	}
-
-	// 合并所有找到的进程ID
-	var allPids []uint32
-	allPids = append(allPids, wechatAppExPids...)
-	allPids = append(allPids, wechatBrowserPids...)
-
-	if len(allPids) == 0 {
-		fmt.Println("未找到 WeChatAppEx.exe 或 WechatBrowser.exe 进程")
-		log.Println("未找到 WeChatAppEx.exe 或 WechatBrowser.exe 进程")
+	targets := buildTargets(*filePath, base, *saveDir)
+	if len(targets) == 0 {
 		return
 	}
-
-	fmt.Printf("找到 %d 个进程: WeChatAppEx.exe(%d个) WechatBrowser.exe(%d个) -> %v\n",
-		len(allPids), len(wechatAppExPids), len(wechatBrowserPids), allPids)
-	log.Printf("找到 %d 个进程: WeChatAppEx.exe(%d个) WechatBrowser.exe(%d个) -> %v",
-		len(allPids), len(wechatAppExPids), len(wechatBrowserPids), allPids)
 	fmt.Println()
 
 	// 设置信号处理
@@ -129,31 +153,39 @@ func main() {
 
 	// 开始搜索
 	totalMatches := 0
-	pattern := memoryscanner.StringToPattern(searchStr, searchLength)
+	// 模式只包含搜索字符串，匹配内容按结束规则向后扩展到最多 searchLength 字节
+	config := scanConfig{
+		pattern:      memoryscanner.StringToPattern(searchStr, 0),
+		maxLength:    searchLength,
+		terminator:   terminator,
+		workers:      *workers,
+		memoryBudget: *budgetMB << 20,
+	}
 
-	for _, pid := range allPids {
+	for _, target := range targets {
 		select {
 		case <-ctx.Done():
 			return
 		default:
 		}
 
-		fmt.Printf("正在扫描进程 %d...\n", pid)
-		log.Printf("开始扫描进程 %d", pid)
+		fmt.Printf("正在扫描%s...\n", target.name)
+		log.Printf("开始扫描%s", target.name)
 
-		matches, err := scanProcess(ctx, pid, pattern)
+		matches, stats, err := scanTargetMemory(ctx, target, config)
 		if err != nil {
-			fmt.Printf("扫描进程 %d 失败: %v\n", pid, err)
-			log.Printf("扫描进程 %d 失败: %v", pid, err)
+			fmt.Printf("扫描%s失败: %s\n", target.name, describeScanError(err))
+			log.Printf("扫描%s失败: %v", target.name, err)
 			continue
 		}
+		printScanStats(target.name, stats)
 
 		if len(matches) == 0 {
-			fmt.Printf("进程 %d 中未找到匹配项\n", pid)
-			log.Printf("进程 %d 中未找到匹配项", pid)
+			fmt.Printf("%s 中未找到匹配项\n", target.name)
+			log.Printf("%s 中未找到匹配项", target.name)
 		} else {
-			fmt.Printf("进程 %d 中找到 %d 个匹配项:\n", pid, len(matches))
-			log.Printf("进程 %d 中找到 %d 个匹配项", pid, len(matches))
+			fmt.Printf("%s 中找到 %d 个匹配项:\n", target.name, len(matches))
+			log.Printf("%s 中找到 %d 个匹配项", target.name, len(matches))
 
 			for i, match := range matches {
 				content := match.Content()
@@ -198,48 +230,283 @@ func setupLogFile() (*os.File, error) {
 	return file, nil
 }
 
-// scanProcess 扫描单个进程的内存
-func scanProcess(ctx context.Context, pid uint32, pattern string) ([]memoryscanner.Match, error) {
+// buildTargets 根据命令行参数确定扫描目标：指定的内存文件，或者所有微信相关进程
+func buildTargets(filePath string, base memoryscanner.Address, saveDir string) []scanTarget {
+	if filePath == "" {
+		return findProcessTargets(saveDir)
+	}
+
+	log.Printf("扫描文件: %s (基址: %s)", filePath, base)
+	return []scanTarget{{
+		name: "文件 " + filePath,
+		open: func(ctx context.Context) (*memoryscanner.Scanner, error) {
+			source, err := openFileSource(filePath, base)
+			if err != nil {
+				return nil, err
+			}
+			return memoryscanner.NewScannerFromSource(source), nil
+		},
+	}}
+}
+
+// printMemoryMap 打印目标的内存区域表
+func printMemoryMap(ctx context.Context, target scanTarget) error {
+	scanner, err := target.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer scanner.Close()
+
+	regions, err := scanner.Regions(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s 的内存区域 (%d 个):\n", target.name, len(regions))
+	fmt.Printf("  %-18s %-18s %12s  %-10s %-8s %-8s %s\n", "起始地址", "结束地址", "大小", "保护", "状态", "类型", "名称")
+
+	var readableBytes uint64
+	for _, region := range regions {
+		fmt.Printf("  %-18s %-18s %12d  %-10s %-8s %-8s %s\n",
+			region.BaseAddress, region.End(), region.Size,
+			region.Protection, region.State, region.Type, region.Name)
+		if region.Readable() {
+			readableBytes += region.Size
+		}
+	}
+	fmt.Printf("可读内存共 %d 字节 (%.1f MB)\n\n", readableBytes, float64(readableBytes)/(1<<20))
+
+	return nil
+}
+
+// findProcessTargets 搜索所有微信相关进程，saveDir 不为空时扫描前先保存快照
+func findProcessTargets(saveDir string) []scanTarget {
+	// 搜索所有 WeChatAppEx.exe 进程
+	fmt.Println("正在搜索 WeChatAppEx.exe 进程...")
+	wechatAppExPids, err := memoryscanner.FindProcessesByName("WeChatAppEx.exe")
+	if err != nil {
+		reportFindError("WeChatAppEx.exe", err)
+		wechatAppExPids = []uint32{}
+	}
+
+	// 搜索所有 WechatBrowser.exe 进程
+	fmt.Println("正在搜索 WechatBrowser.exe 进程...")
+	wechatBrowserPids, err := memoryscanner.FindProcessesByName("WechatBrowser.exe")
+	if err != nil {
+		reportFindError("WechatBrowser.exe", err)
+		wechatBrowserPids = []uint32{}
+	}
+
+	// 合并所有找到的进程ID
+	var allPids []uint32
+	allPids = append(allPids, wechatAppExPids...)
+	allPids = append(allPids, wechatBrowserPids...)
+
+	if len(allPids) == 0 {
+		fmt.Println("未找到 WeChatAppEx.exe 或 WechatBrowser.exe 进程")
+		log.Println("未找到 WeChatAppEx.exe 或 WechatBrowser.exe 进程")
+		return nil
+	}
+
+	fmt.Printf("找到 %d 个进程: WeChatAppEx.exe(%d个) WechatBrowser.exe(%d个) -> %v\n",
+		len(allPids), len(wechatAppExPids), len(wechatBrowserPids), allPids)
+	log.Printf("找到 %d 个进程: WeChatAppEx.exe(%d个) WechatBrowser.exe(%d个) -> %v",
+		len(allPids), len(wechatAppExPids), len(wechatBrowserPids), allPids)
+
+	targets := make([]scanTarget, len(allPids))
+	for i, pid := range allPids {
+		targets[i] = scanTarget{
+			name: fmt.Sprintf("进程 %d", pid),
+			open: func(ctx context.Context) (*memoryscanner.Scanner, error) {
+				if saveDir != "" {
+					return saveProcessSnapshot(ctx, pid, saveDir)
+				}
+				return memoryscanner.NewScanner(pid)
+			},
+		}
+	}
+	return targets
+}
+
+// reportFindError 显示查找进程失败的原因，进程不存在是正常情况，不提示
+func reportFindError(name string, err error) {
+	if errors.Is(err, memoryscanner.ErrProcessNotFound) {
+		return
+	}
+	fmt.Printf("查找 %s 进程失败: %v\n", name, err)
+	log.Printf("查找 %s 进程失败: %v", name, err)
+}
+
+// describeScanError 将扫描错误转换为更容易理解的提示
+func describeScanError(err error) string {
+	switch {
+	case errors.Is(err, memoryscanner.ErrAccessDenied):
+		return fmt.Sprintf("权限不足，请以管理员身份运行 (%v)", err)
+	case errors.Is(err, memoryscanner.ErrProcessExited):
+		return fmt.Sprintf("进程已退出 (%v)", err)
+	case errors.Is(err, memoryscanner.ErrProcessNotFound):
+		return fmt.Sprintf("进程不存在 (%v)", err)
+	case errors.Is(err, memoryscanner.ErrInvalidPattern):
+		return fmt.Sprintf("搜索模式无效 (%v)", err)
+	default:
+		return err.Error()
+	}
+}
+
+// saveProcessSnapshot 将进程内存保存为快照文件，并返回扫描该快照的扫描器
+func saveProcessSnapshot(ctx context.Context, pid uint32, saveDir string) (*memoryscanner.Scanner, error) {
 	scanner, err := memoryscanner.NewScanner(pid)
 	if err != nil {
-		return nil, fmt.Errorf("创建扫描器失败: %w", err)
+		return nil, err
 	}
 	defer scanner.Close()
 
-	var matches []memoryscanner.Match
-	matchCount := 0
-	scanOpts := memoryscanner.ScanOptions{
-		Pattern:    pattern,
-		IgnoreCase: true,
+	timestamp := time.Now().Format("2006-01-02_15-04-05")
+	path := filepath.Join(saveDir, fmt.Sprintf("%s_%d_%s.snap", logFilePrefix, pid, timestamp))
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("创建快照文件失败: %w", err)
+	}
+
+	err = scanner.WriteSnapshot(ctx, file, memoryscanner.SnapshotOptions{
 		MinAddress: 0x0,
 		MaxAddress: 0x7FFFFFFFFFFF,
-		Handler: func(match memoryscanner.Match) bool {
-			matches = append(matches, match)
-			matchCount++
+	})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("保存快照失败: %w", err)
+	}
 
-			// 实时显示进度，每100个匹配项显示一次
-			if matchCount%100 == 0 {
-				fmt.Printf("\r进程 %d 已找到 %d 个匹配项...", pid, matchCount)
-			}
+	fmt.Printf("已保存快照: %s\n", path)
+	log.Printf("进程 %d 的快照已保存到 %s", pid, path)
 
+	snapshot, err := memoryscanner.OpenSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	return memoryscanner.NewScannerFromSource(snapshot), nil
+}
+
+// openFileSource 根据文件头识别文件格式并打开对应的内存源
+func openFileSource(path string, base memoryscanner.Address) (memoryscanner.MemorySource, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return memoryscanner.OpenRawDir(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, 4)
+	_, err = io.ReadFull(file, magic)
+	file.Close()
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	switch string(magic) {
+	case "\x7fELF":
+		return memoryscanner.OpenCoreDump(path)
+	case "MDMP":
+		return memoryscanner.OpenMinidump(path)
+	case "MEMS":
+		return memoryscanner.OpenSnapshot(path)
+	default:
+		return memoryscanner.OpenRawFiles(memoryscanner.RawFile{Path: path, BaseAddress: base})
+	}
+}
+
+// scanTargetMemory 扫描单个目标的内存
+func scanTargetMemory(ctx context.Context, target scanTarget, config scanConfig) ([]memoryscanner.Match, memoryscanner.ScanStats, error) {
+	var stats memoryscanner.ScanStats
+	scanner, err := target.open(ctx)
+	if err != nil {
+		return nil, stats, fmt.Errorf("创建扫描器失败: %w", err)
+	}
+	defer scanner.Close()
+
+	var matches []memoryscanner.Match
+	progressShown := false
+	scanOpts := memoryscanner.ScanOptions{
+		Pattern:        config.pattern,
+		IgnoreCase:     true,
+		MinAddress:     0x0,
+		MaxAddress:     0x7FFFFFFFFFFF,
+		Terminator:     config.terminator,
+		MaxMatchLength: config.maxLength,
+		Workers:        config.workers,
+		Ordered:        true,
+		MemoryBudget:   config.memoryBudget,
+		Stats:          &stats,
+		Handler: func(match memoryscanner.Match) bool {
+			matches = append(matches, match)
 			return true
 		},
+		// 实时显示扫描进度和预计剩余时间
+		Progress: func(progress memoryscanner.ScanProgress) {
+			fmt.Printf("\r%s 进度 %5.1f%%，已找到 %d 个匹配项，预计剩余 %v   ",
+				target.name, progress.Percent(), progress.Matches, progress.Remaining().Round(time.Second))
+			progressShown = true
+		},
 	}
 
 	err = scanner.Scan(ctx, scanOpts)
+
+	// 如果有进度显示，换行
+	if progressShown {
+		fmt.Println()
+	}
+
 	if err != nil {
-		if err == context.Canceled {
-			return matches, nil
+		if errors.Is(err, context.Canceled) {
+			return matches, stats, nil
 		}
-		return matches, fmt.Errorf("扫描失败: %w", err)
+		return matches, stats, fmt.Errorf("扫描失败: %w", err)
 	}
 
-	// 如果有进度显示，换行
-	if matchCount >= 100 {
-		fmt.Println()
+	return matches, stats, nil
+}
+
+// parseTerminator 将 -term 参数转换为结束规则
+func parseTerminator(name string) (memoryscanner.Terminator, bool) {
+	for _, terminator := range []memoryscanner.Terminator{
+		memoryscanner.TerminatorNone,
+		memoryscanner.TerminatorNUL,
+		memoryscanner.TerminatorUTF16NUL,
+		memoryscanner.TerminatorNonPrintable,
+	} {
+		if strings.EqualFold(name, terminator.String()) {
+			return terminator, true
+		}
 	}
+	return memoryscanner.TerminatorNone, false
+}
 
-	return matches, nil
+// printScanStats 显示扫描覆盖的内存范围，便于区分"未找到"和"没有读到内存"
+func printScanStats(name string, stats memoryscanner.ScanStats) {
+	skipped := stats.RegionsSkippedState + stats.RegionsSkippedProtection +
+		stats.RegionsSkippedFilter + stats.RegionsSkippedReadError
+	summary := fmt.Sprintf("%s 扫描了 %d 个区域 (%.1f MB)，%.1f MB 不可读，跳过 %d 个区域"+
+		"（未提交 %d，不可读保护 %d，已过滤 %d，读取失败 %d），用时 %v，%.1f MB/s",
+		name, stats.RegionsVisited, float64(stats.BytesScanned)/(1<<20),
+		float64(stats.BytesUnreadable)/(1<<20), skipped,
+		stats.RegionsSkippedState, stats.RegionsSkippedProtection,
+		stats.RegionsSkippedFilter, stats.RegionsSkippedReadError,
+		stats.Elapsed.Round(time.Millisecond), stats.Throughput()/(1<<20))
+	fmt.Println(summary)
+	log.Println(summary)
+
+	if stats.BytesScanned == 0 {
+		fmt.Printf("警告: 没有读取到%s的任何内存，请检查是否有足够的权限\n", name)
+		log.Printf("警告: 没有读取到%s的任何内存", name)
+	}
 }
 
 // formatForConsole 格式化字符串用于控制台显示，将换行符替换为\n并截断
@@ -0,0 +1,216 @@
+package memoryscanner
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ntFile is the note type of the NT_FILE note listing file-backed mappings
+const ntFile = 0x46494c45
+
+// maxCoreNoteSize bounds the PT_NOTE segments read into memory. Cores of
+// processes with many threads and mappings have notes of a few megabytes.
+const maxCoreNoteSize = 64 << 20
+
+// coreFileMapping is one entry of the NT_FILE note
+type coreFileMapping struct {
+	start uint64
+	end   uint64
+	name  string
+}
+
+// CoreDumpSource is a MemorySource that reads an ELF core file, such as one
+// written by gcore or the kernel. Addresses are the virtual addresses of the
+// process the core was taken from.
+type CoreDumpSource struct {
+	*segmentSource
+}
+
+// OpenCoreDump opens an ELF core file for scanning
+func OpenCoreDump(path string) (*CoreDumpSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := newCoreDumpSource(file, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// newCoreDumpSource parses the core file in r, closer is closed with the source
+func newCoreDumpSource(r io.ReaderAt, closer io.Closer) (*CoreDumpSource, error) {
+	elfFile, err := elf.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ELF file: %w", err)
+	}
+
+	if elfFile.Type != elf.ET_CORE {
+		return nil, fmt.Errorf("not a core file: ELF type %s", elfFile.Type)
+	}
+
+	var mappings []coreFileMapping
+	for _, prog := range elfFile.Progs {
+		if prog.Type != elf.PT_NOTE {
+			continue
+		}
+
+		noteMappings, err := parseCoreNotes(prog, elfFile.Class, elfFile.ByteOrder)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, noteMappings...)
+	}
+
+	var regions []Region
+	var progs []*elf.Prog
+	for _, prog := range elfFile.Progs {
+		if prog.Type != elf.PT_LOAD || prog.Memsz == 0 {
+			continue
+		}
+
+		region := Region{
+			BaseAddress: Address(prog.Vaddr),
+			Size:        prog.Memsz,
+			Protection:  protectionFromELF(prog.Flags),
+			State:       StateCommit,
+			Type:        TypePrivate,
+		}
+		for _, mapping := range mappings {
+			if mapping.start <= prog.Vaddr && prog.Vaddr < mapping.end {
+				region.Name = mapping.name
+				region.Type = TypeMapped
+				break
+			}
+		}
+
+		regions = append(regions, region)
+		progs = append(progs, prog)
+	}
+
+	markImageRegions(regions)
+
+	segments := make([]segment, len(regions))
+	for i, prog := range progs {
+		// Segments the kernel chose not to dump have no file data
+		segments[i] = segment{
+			region:   regions[i],
+			reader:   prog,
+			dataSize: min(prog.Filesz, prog.Memsz),
+		}
+	}
+
+	return &CoreDumpSource{segmentSource: newSegmentSource(segments, closer)}, nil
+}
+
+// protectionFromELF converts program header flags to Protection
+func protectionFromELF(flags elf.ProgFlag) Protection {
+	var p Protection
+	if flags&elf.PF_R != 0 {
+		p |= ProtectRead
+	}
+	if flags&elf.PF_W != 0 {
+		p |= ProtectWrite
+	}
+	if flags&elf.PF_X != 0 {
+		p |= ProtectExecute
+	}
+	return p
+}
+
+// parseCoreNotes walks the notes of a PT_NOTE segment and returns the NT_FILE mappings
+func parseCoreNotes(prog *elf.Prog, class elf.Class, order binary.ByteOrder) ([]coreFileMapping, error) {
+	if prog.Filesz > maxCoreNoteSize {
+		return nil, fmt.Errorf("note segment of %d bytes is too large", prog.Filesz)
+	}
+	// Read through the segment reader so that a size larger than the file
+	// does not allocate more than the file holds
+	data, err := io.ReadAll(prog.Open())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read note segment: %w", err)
+	}
+	if uint64(len(data)) < prog.Filesz {
+		return nil, errors.New("truncated note segment")
+	}
+
+	var mappings []coreFileMapping
+	for len(data) >= 12 {
+		nameSize := uint64(order.Uint32(data[0:4]))
+		descSize := uint64(order.Uint32(data[4:8]))
+		noteType := order.Uint32(data[8:12])
+		data = data[12:]
+
+		// Core file notes are 4-byte aligned regardless of the ELF class
+		nameEnd := alignUp(nameSize, 4)
+		descEnd := nameEnd + alignUp(descSize, 4)
+		if descEnd > uint64(len(data)) {
+			return nil, errors.New("truncated note segment")
+		}
+
+		if noteType == ntFile && bytes.Equal(bytes.TrimRight(data[:nameSize], "\x00"), []byte("CORE")) {
+			fileMappings, err := parseNTFile(data[nameEnd:nameEnd+descSize], class, order)
+			if err != nil {
+				return nil, err
+			}
+			mappings = append(mappings, fileMappings...)
+		}
+
+		data = data[descEnd:]
+	}
+
+	return mappings, nil
+}
+
+// parseNTFile decodes the NT_FILE note: a count and page size, then
+// (start, end, file offset) triples, then the NUL-terminated file names
+func parseNTFile(desc []byte, class elf.Class, order binary.ByteOrder) ([]coreFileMapping, error) {
+	wordSize := 8
+	word := order.Uint64
+	if class == elf.ELFCLASS32 {
+		wordSize = 4
+		word = func(b []byte) uint64 { return uint64(order.Uint32(b)) }
+	}
+
+	if len(desc) < 2*wordSize {
+		return nil, errors.New("truncated NT_FILE note")
+	}
+
+	count := word(desc)
+	desc = desc[2*wordSize:]
+	if count > uint64(len(desc)/(3*wordSize)) {
+		return nil, errors.New("truncated NT_FILE note")
+	}
+
+	mappings := make([]coreFileMapping, count)
+	for i := range mappings {
+		entry := desc[i*3*wordSize:]
+		mappings[i].start = word(entry)
+		mappings[i].end = word(entry[wordSize:])
+	}
+
+	names := desc[int(count)*3*wordSize:]
+	for i := range mappings {
+		name, rest, found := bytes.Cut(names, []byte{0})
+		if !found && len(name) == 0 {
+			return nil, errors.New("truncated NT_FILE note")
+		}
+		mappings[i].name = string(name)
+		names = rest
+	}
+
+	return mappings, nil
+}
+
+// alignUp rounds n up to a multiple of align
+func alignUp(n, align uint64) uint64 {
+	return (n + align - 1) &^ (align - 1)
+}
@@ -0,0 +1,210 @@
+package memoryscanner
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// 测试用的 PT_LOAD 段
+type testCoreSegment struct {
+	vaddr  uint64
+	flags  uint32
+	memsz  uint64
+	data   []byte
+	mapped string
+}
+
+// 辅助函数：构造一个最小的 64 位小端 ELF core 文件
+func writeTestCore(t *testing.T, segments []testCoreSegment) string {
+	t.Helper()
+	le := binary.LittleEndian
+
+	// NT_FILE 描述：数量、页大小、(start, end, offset) 三元组、文件名
+	var files []testCoreSegment
+	for _, seg := range segments {
+		if seg.mapped != "" {
+			files = append(files, seg)
+		}
+	}
+	var desc []byte
+	desc = le.AppendUint64(desc, uint64(len(files)))
+	desc = le.AppendUint64(desc, 0x1000)
+	for _, seg := range files {
+		desc = le.AppendUint64(desc, seg.vaddr)
+		desc = le.AppendUint64(desc, seg.vaddr+seg.memsz)
+		desc = le.AppendUint64(desc, 0)
+	}
+	for _, seg := range files {
+		desc = append(desc, seg.mapped...)
+		desc = append(desc, 0)
+	}
+	for len(desc)%4 != 0 {
+		desc = append(desc, 0)
+	}
+
+	var note []byte
+	note = le.AppendUint32(note, 5)
+	note = le.AppendUint32(note, uint32(len(desc)))
+	note = le.AppendUint32(note, ntFile)
+	note = append(note, "CORE\x00\x00\x00\x00"...)
+	note = append(note, desc...)
+
+	const headerSize, phdrSize = 64, 56
+	phnum := len(segments) + 1
+	offset := uint64(headerSize + phdrSize*phnum)
+
+	var header []byte
+	header = append(header, 0x7F, 'E', 'L', 'F', 2, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	header = le.AppendUint16(header, uint16(4)) // ET_CORE
+	header = le.AppendUint16(header, 62)        // EM_X86_64
+	header = le.AppendUint32(header, 1)
+	header = le.AppendUint64(header, 0)
+	header = le.AppendUint64(header, headerSize)
+	header = le.AppendUint64(header, 0)
+	header = le.AppendUint32(header, 0)
+	header = le.AppendUint16(header, headerSize)
+	header = le.AppendUint16(header, phdrSize)
+	header = le.AppendUint16(header, uint16(phnum))
+	header = le.AppendUint16(header, 0)
+	header = le.AppendUint16(header, 0)
+	header = le.AppendUint16(header, 0)
+
+	appendPhdr := func(b []byte, typ, flags uint32, off, vaddr, filesz, memsz uint64) []byte {
+		b = le.AppendUint32(b, typ)
+		b = le.AppendUint32(b, flags)
+		b = le.AppendUint64(b, off)
+		b = le.AppendUint64(b, vaddr)
+		b = le.AppendUint64(b, 0)
+		b = le.AppendUint64(b, filesz)
+		b = le.AppendUint64(b, memsz)
+		return le.AppendUint64(b, 1)
+	}
+
+	header = appendPhdr(header, 4, 0, offset, 0, uint64(len(note)), 0) // PT_NOTE
+	body := note
+	offset += uint64(len(note))
+	for _, seg := range segments {
+		header = appendPhdr(header, 1, seg.flags, offset, seg.vaddr, uint64(len(seg.data)), seg.memsz) // PT_LOAD
+		body = append(body, seg.data...)
+		offset += uint64(len(seg.data))
+	}
+
+	path := filepath.Join(t.TempDir(), "core")
+	if err := os.WriteFile(path, append(header, body...), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestCoreDumpSource(t *testing.T) {
+	path := writeTestCore(t, []testCoreSegment{
+		{vaddr: 0x400000, flags: 5, memsz: 0x1000, data: makeRegionData(0x1000, map[int]string{0x20: "WeChat"}), mapped: "/opt/wechat/WeChatAppEx"},
+		{vaddr: 0x401000, flags: 4, memsz: 0x1000, data: makeRegionData(0x1000, nil), mapped: "/opt/wechat/WeChatAppEx"},
+		{vaddr: 0x7F0000, flags: 6, memsz: 0x2000, data: makeRegionData(0x2000, map[int]string{0x1FFA: "WeChat"})},
+		// 未被转储的段：只有 memsz，没有文件数据
+		{vaddr: 0x900000, flags: 4, memsz: 0x1000},
+	})
+
+	source, err := OpenCoreDump(path)
+	if err != nil {
+		t.Fatalf("OpenCoreDump failed: %v", err)
+	}
+	scanner := NewScannerFromSource(source)
+	defer scanner.Close()
+
+	regions, err := source.Regions(context.Background())
+	if err != nil {
+		t.Fatalf("Regions failed: %v", err)
+	}
+
+	expected := []Region{
+		{BaseAddress: 0x400000, Size: 0x1000, Protection: ProtectRead | ProtectExecute, Type: TypeImage, Name: "/opt/wechat/WeChatAppEx"},
+		{BaseAddress: 0x401000, Size: 0x1000, Protection: ProtectRead, Type: TypeImage, Name: "/opt/wechat/WeChatAppEx"},
+		{BaseAddress: 0x7F0000, Size: 0x2000, Protection: ProtectRead | ProtectWrite, Type: TypePrivate},
+		{BaseAddress: 0x900000, Size: 0x1000, Protection: ProtectRead, Type: TypePrivate},
+	}
+	if !slices.Equal(regions, expected) {
+		t.Errorf("Regions() = %+v, want %+v", regions, expected)
+	}
+
+	result := scanAddresses(t, scanner, ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		MaxAddress: 0x7FFFFFFFFFFF,
+	})
+	if want := []Address{0x400020, 0x7F1FFA}; !slices.Equal(result, want) {
+		t.Errorf("Scan addresses = %v, want %v", result, want)
+	}
+
+	// 未转储的段不可读
+	if n, err := source.ReadAt(make([]byte, 16), 0x900000); err == nil || n != 0 {
+		t.Errorf("ReadAt(0x900000) = %d, %v; want read error", n, err)
+	}
+}
+
+func TestCoreDumpSourceNotCore(t *testing.T) {
+	// 非 core 类型的 ELF 文件应被拒绝
+	executable, err := os.Executable()
+	if err != nil {
+		t.Skipf("无法获取测试程序路径: %v", err)
+	}
+
+	if _, err := OpenCoreDump(executable); err == nil {
+		t.Error("OpenCoreDump on a non-core file succeeded, want error")
+	}
+}
+
+func TestOpenCoreDumpMalformed(t *testing.T) {
+	// PT_NOTE 是第一个程序头，注释段紧跟在两个程序头之后
+	const noteFileszOffset = 64 + 32
+	const noteOffset = 64 + 56*2
+
+	tests := []struct {
+		name  string
+		patch func(data []byte) []byte
+	}{
+		{"note size overflows", func(data []byte) []byte {
+			binary.LittleEndian.PutUint64(data[noteFileszOffset:], 1<<50)
+			return data
+		}},
+		{"note past end of file", func(data []byte) []byte {
+			binary.LittleEndian.PutUint64(data[noteFileszOffset:], 1<<20)
+			return data
+		}},
+		{"note descriptor too large", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[noteOffset+4:], 0xFFFFFFF0)
+			return data
+		}},
+		{"NT_FILE count too large", func(data []byte) []byte {
+			binary.LittleEndian.PutUint64(data[noteOffset+20:], 1<<60)
+			return data
+		}},
+		{"truncated file", func(data []byte) []byte {
+			return data[:noteOffset+16]
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestCore(t, []testCoreSegment{
+				{vaddr: 0x400000, flags: 5, memsz: 0x1000, data: makeRegionData(0x1000, nil), mapped: "/opt/wechat/WeChatAppEx"},
+			})
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile failed: %v", err)
+			}
+			if err := os.WriteFile(path, tt.patch(data), 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+
+			// 损坏的文件返回错误而不是崩溃
+			if source, err := OpenCoreDump(path); err == nil {
+				source.Close()
+				t.Error("OpenCoreDump on a malformed file succeeded, want error")
+			}
+		})
+	}
+}
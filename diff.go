@@ -0,0 +1,248 @@
+package memoryscanner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// diffChunkSize is how many bytes of each capture are compared at a time
+const diffChunkSize = 1 << 20
+
+// MemoryChange is a run of consecutive bytes that differ between two captures
+type MemoryChange struct {
+	Address Address
+	Old     []byte
+	New     []byte
+}
+
+// DiffOptions contains configuration options for comparing two captures
+type DiffOptions struct {
+	// Minimum address to compare from (inclusive)
+	MinAddress Address
+	// Maximum address to compare to (exclusive)
+	MaxAddress Address
+}
+
+// DiffResult is the outcome of comparing two captures of the same process
+type DiffResult struct {
+	// Changes lists the byte ranges readable in both captures whose contents differ
+	Changes []MemoryChange
+	// Added lists the parts of readable regions that only exist in the new capture
+	Added []Region
+	// Removed lists the parts of readable regions that only exist in the old capture
+	Removed []Region
+}
+
+// Diff compares two captures of the same process, such as two snapshots or a
+// snapshot and the live process. Only readable regions between MinAddress and
+// MaxAddress are considered, the same regions Scan would search.
+func Diff(ctx context.Context, oldSource, newSource MemorySource, opts DiffOptions) (*DiffResult, error) {
+	oldRegions, err := readableRegions(ctx, oldSource, opts.MinAddress, opts.MaxAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate old regions: %w", err)
+	}
+	newRegions, err := readableRegions(ctx, newSource, opts.MinAddress, opts.MaxAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate new regions: %w", err)
+	}
+
+	result := &DiffResult{
+		Added:   subtractRegions(newRegions, oldRegions),
+		Removed: subtractRegions(oldRegions, newRegions),
+	}
+
+	differ := &memoryDiffer{
+		oldSource: oldSource,
+		newSource: newSource,
+		oldBuffer: make([]byte, diffChunkSize),
+		newBuffer: make([]byte, diffChunkSize),
+	}
+
+	// Compare the address ranges readable in both captures
+	i, j := 0, 0
+	for i < len(oldRegions) && j < len(newRegions) {
+		start := max(oldRegions[i].BaseAddress, newRegions[j].BaseAddress)
+		end := min(oldRegions[i].End(), newRegions[j].End())
+		if start < end {
+			if err := differ.compare(ctx, start, end); err != nil {
+				return nil, err
+			}
+		}
+
+		if oldRegions[i].End() < newRegions[j].End() {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	result.Changes = differ.finish()
+	return result, nil
+}
+
+// readableRegions returns the readable regions of source clipped to [minAddress, maxAddress)
+func readableRegions(ctx context.Context, source MemorySource, minAddress, maxAddress Address) ([]Region, error) {
+	regions, err := source.Regions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var readable []Region
+	for _, region := range regions {
+		if !region.Readable() {
+			continue
+		}
+
+		start := max(region.BaseAddress, minAddress)
+		end := min(region.End(), maxAddress)
+		if end <= start {
+			continue
+		}
+		region.BaseAddress = start
+		region.Size = uint64(end - start)
+		readable = append(readable, region)
+	}
+	return readable, nil
+}
+
+// subtractRegions returns the parts of regions not covered by any of the other regions.
+// Both lists must be sorted and non-overlapping.
+func subtractRegions(regions, others []Region) []Region {
+	var result []Region
+	j := 0
+	for _, region := range regions {
+		start := region.BaseAddress
+		end := region.End()
+
+		for j < len(others) && others[j].End() <= start {
+			j++
+		}
+		for k := j; k < len(others) && others[k].BaseAddress < end && start < end; k++ {
+			if others[k].BaseAddress > start {
+				piece := region
+				piece.BaseAddress = start
+				piece.Size = uint64(others[k].BaseAddress - start)
+				result = append(result, piece)
+			}
+			start = max(start, others[k].End())
+		}
+
+		if start < end {
+			piece := region
+			piece.BaseAddress = start
+			piece.Size = uint64(end - start)
+			result = append(result, piece)
+		}
+	}
+	return result
+}
+
+// memoryDiffer compares two sources chunk by chunk and collects the changes,
+// joining runs of changed bytes that continue across chunk boundaries
+type memoryDiffer struct {
+	oldSource MemorySource
+	newSource MemorySource
+	oldBuffer []byte
+	newBuffer []byte
+	changes   []MemoryChange
+	// current is the change still open at the end of the previous chunk
+	current *MemoryChange
+}
+
+// compare compares the bytes of both sources in [start, end)
+func (d *memoryDiffer) compare(ctx context.Context, start, end Address) error {
+	for address := start; address < end; {
+		// Check if context was cancelled
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		length := int(min(uint64(end-address), diffChunkSize))
+		oldRuns, err := readWithFallback(d.oldSource, d.oldBuffer[:length], address)
+		if err != nil {
+			return err
+		}
+		newRuns, err := readWithFallback(d.newSource, d.newBuffer[:length], address)
+		if err != nil {
+			return err
+		}
+
+		// Bytes that could not be read from either capture are not compared
+		i, j := 0, 0
+		for i < len(oldRuns) && j < len(newRuns) {
+			start := max(oldRuns[i].start, newRuns[j].start)
+			stop := min(oldRuns[i].end, newRuns[j].end)
+			if start < stop {
+				d.compareChunk(address+Address(start), d.oldBuffer[start:stop], d.newBuffer[start:stop])
+			}
+
+			if oldRuns[i].end < newRuns[j].end {
+				i++
+			} else {
+				j++
+			}
+		}
+
+		address += Address(length)
+	}
+
+	return nil
+}
+
+// compareChunk records the differing runs of two equally sized buffers read at address
+func (d *memoryDiffer) compareChunk(address Address, oldData, newData []byte) {
+	if d.current != nil && d.current.Address+Address(len(d.current.Old)) != address {
+		d.closeChange()
+	}
+
+	for i := 0; i < len(oldData); {
+		if oldData[i] == newData[i] {
+			d.closeChange()
+			i = nextDifference(oldData, newData, i)
+			continue
+		}
+
+		runEnd := i + 1
+		for runEnd < len(oldData) && oldData[runEnd] != newData[runEnd] {
+			runEnd++
+		}
+
+		if d.current == nil {
+			d.current = &MemoryChange{Address: address + Address(i)}
+		}
+		d.current.Old = append(d.current.Old, oldData[i:runEnd]...)
+		d.current.New = append(d.current.New, newData[i:runEnd]...)
+		i = runEnd
+	}
+}
+
+// nextDifference returns the index of the first differing byte at or after from,
+// or len(a) if the rest is equal. Equal stretches are skipped in blocks.
+func nextDifference(a, b []byte, from int) int {
+	const block = 64
+	i := from
+	for i+block <= len(a) && bytes.Equal(a[i:i+block], b[i:i+block]) {
+		i += block
+	}
+	for i < len(a) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// closeChange finishes the open change, if any
+func (d *memoryDiffer) closeChange() {
+	if d.current != nil {
+		d.changes = append(d.changes, *d.current)
+		d.current = nil
+	}
+}
+
+// finish closes the open change and returns all changes
+func (d *memoryDiffer) finish() []MemoryChange {
+	d.closeChange()
+	return d.changes
+}
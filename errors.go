@@ -0,0 +1,62 @@
+package memoryscanner
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrProcessNotFound is returned when no process has the requested name or PID
+	ErrProcessNotFound = errors.New("process not found")
+	// ErrAccessDenied is returned when the process exists but may not be opened or read
+	ErrAccessDenied = errors.New("access denied")
+	// ErrProcessExited is returned when the process exits while it is being scanned
+	ErrProcessExited = errors.New("process has exited")
+	// ErrInvalidPattern is returned for patterns that cannot be parsed, always
+	// wrapped in a *PatternError
+	ErrInvalidPattern = errors.New("invalid pattern")
+)
+
+// ProcessError reports why a process could not be opened or read.
+// It matches both its Kind and the underlying system error with errors.Is.
+type ProcessError struct {
+	PID uint32
+	// Kind is ErrProcessNotFound, ErrAccessDenied or ErrProcessExited
+	Kind error
+	// Err is the error returned by the operating system
+	Err error
+}
+
+// Error returns the error message
+func (e *ProcessError) Error() string {
+	return fmt.Sprintf("process %d: %v: %v", e.PID, e.Kind, e.Err)
+}
+
+// Unwrap returns the kind and the system error
+func (e *ProcessError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// PatternError reports the part of a pattern that could not be parsed
+type PatternError struct {
+	Pattern string
+	// Pos is the byte offset of Token in Pattern
+	Pos int
+	// Token is the offending part of the pattern, empty if the pattern is empty
+	Token string
+	// Msg describes the problem
+	Msg string
+}
+
+// Error returns the error message
+func (e *PatternError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("invalid pattern: %s", e.Msg)
+	}
+	return fmt.Sprintf("invalid pattern at position %d (%q): %s", e.Pos, e.Token, e.Msg)
+}
+
+// Is reports whether target is ErrInvalidPattern
+func (e *PatternError) Is(target error) bool {
+	return target == ErrInvalidPattern
+}
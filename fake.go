@@ -0,0 +1,121 @@
+package memoryscanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// FakeRegion describes a synthetic memory region of a FakeSource
+type FakeRegion struct {
+	// BaseAddress is the first address of the region
+	BaseAddress Address
+	// Data is the content of the region, its length is the region size
+	Data []byte
+	// Protection is the access protection reported for the region
+	Protection Protection
+	// State is the allocation state reported for the region
+	State RegionState
+	// Type is the allocation type reported for the region
+	Type RegionType
+	// Name is the module or mapped file name reported for the region
+	Name string
+	// Unreadable lists sub-ranges whose reads fail, like guard or decommitted pages
+	// in the middle of a live region
+	Unreadable []AddressRange
+}
+
+// region returns the Region describing r
+func (r FakeRegion) region() Region {
+	return Region{
+		BaseAddress: r.BaseAddress,
+		Size:        uint64(len(r.Data)),
+		Protection:  r.Protection,
+		State:       r.State,
+		Type:        r.Type,
+		Name:        r.Name,
+	}
+}
+
+// FakeSource is an in-memory MemorySource built from synthetic regions.
+// It behaves like a live process: reads stop at the first address that is
+// unmapped, not readable or inside an Unreadable range.
+type FakeSource struct {
+	regions []FakeRegion
+	closed  bool
+}
+
+// NewFakeSource creates a fake memory source from the given regions.
+// Regions must not overlap; they are sorted by base address.
+func NewFakeSource(regions ...FakeRegion) *FakeSource {
+	sorted := append([]FakeRegion(nil), regions...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].BaseAddress < sorted[j].BaseAddress
+	})
+	return &FakeSource{regions: sorted}
+}
+
+// Regions returns the regions of the fake source in ascending address order
+func (f *FakeSource) Regions(ctx context.Context) ([]Region, error) {
+	if f.closed {
+		return nil, errors.New("source is closed")
+	}
+
+	regions := make([]Region, len(f.regions))
+	for i, r := range f.regions {
+		regions[i] = r.region()
+	}
+	return regions, nil
+}
+
+// ReadAt copies region contents starting at addr into p, crossing into
+// adjacent regions like ReadProcessMemory does
+func (f *FakeSource) ReadAt(p []byte, addr Address) (int, error) {
+	if f.closed {
+		return 0, errors.New("source is closed")
+	}
+
+	n := 0
+	for n < len(p) {
+		current := addr + Address(n)
+		r, ok := f.find(current)
+		if !ok || !r.region().Readable() {
+			return n, fmt.Errorf("memory at %s is not readable", current)
+		}
+
+		// Stop at the region end or the next unreadable range, whichever comes first
+		limit := r.BaseAddress + Address(len(r.Data))
+		for _, hole := range r.Unreadable {
+			if hole.Start <= current && current < hole.End {
+				return n, fmt.Errorf("memory at %s is not readable", current)
+			}
+			if current < hole.Start && hole.Start < limit {
+				limit = hole.Start
+			}
+		}
+
+		offset := current - r.BaseAddress
+		copied := copy(p[n:], r.Data[offset:limit-r.BaseAddress])
+		n += copied
+	}
+
+	return n, nil
+}
+
+// find returns the region containing addr
+func (f *FakeSource) find(addr Address) (FakeRegion, bool) {
+	i := sort.Search(len(f.regions), func(i int) bool {
+		return f.regions[i].BaseAddress+Address(len(f.regions[i].Data)) > addr
+	})
+	if i < len(f.regions) && f.regions[i].BaseAddress <= addr {
+		return f.regions[i], true
+	}
+	return FakeRegion{}, false
+}
+
+// Close marks the source as closed, later calls fail
+func (f *FakeSource) Close() error {
+	f.closed = true
+	return nil
+}
@@ -0,0 +1,91 @@
+package memoryscanner
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// RegionFilter selects which readable regions a scan searches.
+// The zero value selects every region.
+type RegionFilter struct {
+	// RequireProtection lists protection flags a region must all have, e.g. ProtectWrite
+	RequireProtection Protection
+	// ExcludeProtection lists protection flags a region must not have, e.g. ProtectExecute
+	ExcludeProtection Protection
+	// Types is a mask of region types to include (TypePrivate|TypeMapped), 0 includes all types
+	Types RegionType
+	// IncludeNames keeps only regions whose module or mapped file name matches one of
+	// the patterns. Patterns use path.Match syntax and are compared case-insensitively
+	// against both the full name and its base name, e.g. "WeChatAppEx.exe" or "*.dll".
+	// Backslashes are treated as path separators. Anonymous regions never match.
+	IncludeNames []string
+	// ExcludeNames skips regions whose name matches one of the patterns
+	ExcludeNames []string
+	// MinSize skips regions smaller than this many bytes, 0 means no minimum
+	MinSize uint64
+	// MaxSize skips regions larger than this many bytes, 0 means no maximum
+	MaxSize uint64
+}
+
+// Match reports whether the region passes the filter
+func (f RegionFilter) Match(region Region) bool {
+	if region.Protection&f.RequireProtection != f.RequireProtection {
+		return false
+	}
+	if region.Protection&f.ExcludeProtection != 0 {
+		return false
+	}
+	if f.Types != 0 && region.Type&f.Types == 0 {
+		return false
+	}
+	if region.Size < f.MinSize {
+		return false
+	}
+	if f.MaxSize != 0 && region.Size > f.MaxSize {
+		return false
+	}
+	if len(f.IncludeNames) > 0 && !matchRegionName(f.IncludeNames, region.Name) {
+		return false
+	}
+	if matchRegionName(f.ExcludeNames, region.Name) {
+		return false
+	}
+	return true
+}
+
+// validate checks that the name patterns are well formed
+func (f RegionFilter) validate() error {
+	for _, pattern := range append(append([]string(nil), f.IncludeNames...), f.ExcludeNames...) {
+		if _, err := path.Match(normalizeRegionName(pattern), ""); err != nil {
+			return fmt.Errorf("invalid region name pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchRegionName checks whether name or its base name matches any of the patterns
+func matchRegionName(patterns []string, name string) bool {
+	if name == "" {
+		return false
+	}
+
+	name = normalizeRegionName(name)
+	base := path.Base(name)
+
+	for _, pattern := range patterns {
+		pattern = normalizeRegionName(pattern)
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, base); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeRegionName lowercases a name or pattern and converts Windows path separators
+func normalizeRegionName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), `\`, "/")
+}
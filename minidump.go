@@ -0,0 +1,444 @@
+package memoryscanner
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"unicode/utf16"
+)
+
+// Minidump stream types
+const (
+	minidumpSignature            = 0x504D444D // "MDMP"
+	minidumpModuleListStream     = 4
+	minidumpMemoryListStream     = 5
+	minidumpMemory64ListStream   = 9
+	minidumpMemoryInfoListStream = 16
+)
+
+// minidumpHeader is MINIDUMP_HEADER
+type minidumpHeader struct {
+	Signature          uint32
+	Version            uint32
+	NumberOfStreams    uint32
+	StreamDirectoryRva uint32
+	CheckSum           uint32
+	TimeDateStamp      uint32
+	Flags              uint64
+}
+
+// minidumpDirectory is MINIDUMP_DIRECTORY
+type minidumpDirectory struct {
+	StreamType uint32
+	DataSize   uint32
+	Rva        uint32
+}
+
+// minidumpMemoryDescriptor is MINIDUMP_MEMORY_DESCRIPTOR
+type minidumpMemoryDescriptor struct {
+	StartOfMemoryRange uint64
+	DataSize           uint32
+	Rva                uint32
+}
+
+// minidumpMemoryDescriptor64 is MINIDUMP_MEMORY_DESCRIPTOR64
+type minidumpMemoryDescriptor64 struct {
+	StartOfMemoryRange uint64
+	DataSize           uint64
+}
+
+// minidumpMemoryInfoListHeader is MINIDUMP_MEMORY_INFO_LIST
+type minidumpMemoryInfoListHeader struct {
+	SizeOfHeader    uint32
+	SizeOfEntry     uint32
+	NumberOfEntries uint64
+}
+
+// minidumpMemoryInfo is MINIDUMP_MEMORY_INFO
+type minidumpMemoryInfo struct {
+	BaseAddress       uint64
+	AllocationBase    uint64
+	AllocationProtect uint32
+	_                 uint32
+	RegionSize        uint64
+	State             uint32
+	Protect           uint32
+	Type              uint32
+	_                 uint32
+}
+
+// minidumpModule is the leading part of MINIDUMP_MODULE that the scanner needs,
+// entries are minidumpModuleSize bytes apart
+type minidumpModule struct {
+	BaseOfImage   uint64
+	SizeOfImage   uint32
+	CheckSum      uint32
+	TimeDateStamp uint32
+	ModuleNameRva uint32
+}
+
+const minidumpModuleSize = 108
+
+// minidumpRange is captured memory and its location in the dump file
+type minidumpRange struct {
+	start  uint64
+	size   uint64
+	offset int64
+}
+
+// minidumpModuleInfo is a loaded module and its address range
+type minidumpModuleInfo struct {
+	base uint64
+	size uint64
+	name string
+}
+
+// MinidumpSource is a MemorySource that reads a Windows minidump (.dmp) file.
+// It is pure Go and works on any OS. Region protections, states and types come
+// from the MemoryInfoList stream when the dump has one.
+type MinidumpSource struct {
+	*segmentSource
+}
+
+// OpenMinidump opens a Windows minidump file for scanning
+func OpenMinidump(path string) (*MinidumpSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	source, err := newMinidumpSource(file, info.Size(), file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// newMinidumpSource parses the minidump in r of size bytes, closer is closed with the source
+func newMinidumpSource(r io.ReaderAt, size int64, closer io.Closer) (*MinidumpSource, error) {
+	var header minidumpHeader
+	if err := readStruct(r, 0, &header); err != nil {
+		return nil, fmt.Errorf("failed to read minidump header: %w", err)
+	}
+	if header.Signature != minidumpSignature {
+		return nil, errors.New("not a minidump file")
+	}
+
+	var ranges []minidumpRange
+	var infos []minidumpMemoryInfo
+	var modules []minidumpModuleInfo
+
+	for i := uint32(0); i < header.NumberOfStreams; i++ {
+		var dir minidumpDirectory
+		if err := readStruct(r, int64(header.StreamDirectoryRva)+int64(i)*12, &dir); err != nil {
+			return nil, fmt.Errorf("failed to read stream directory: %w", err)
+		}
+
+		// The counts in a stream are checked against its size, so a stream
+		// that fits in the file cannot claim more entries than the file holds
+		switch dir.StreamType {
+		case minidumpMemoryListStream, minidumpMemory64ListStream, minidumpMemoryInfoListStream, minidumpModuleListStream:
+			if uint64(dir.Rva)+uint64(dir.DataSize) > uint64(size) {
+				return nil, fmt.Errorf("stream %d lies outside the file", dir.StreamType)
+			}
+		}
+
+		var err error
+		switch dir.StreamType {
+		case minidumpMemoryListStream:
+			var memoryRanges []minidumpRange
+			memoryRanges, err = readMinidumpMemoryList(r, dir, size)
+			ranges = append(ranges, memoryRanges...)
+		case minidumpMemory64ListStream:
+			var memoryRanges []minidumpRange
+			memoryRanges, err = readMinidumpMemory64List(r, dir, size)
+			ranges = append(ranges, memoryRanges...)
+		case minidumpMemoryInfoListStream:
+			infos, err = readMinidumpMemoryInfoList(r, dir)
+		case minidumpModuleListStream:
+			modules, err = readMinidumpModuleList(r, dir)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ranges = mergeMinidumpRanges(ranges)
+	return &MinidumpSource{
+		segmentSource: newSegmentSource(buildMinidumpSegments(r, ranges, infos, modules), closer),
+	}, nil
+}
+
+// readStruct decodes a little-endian structure at offset
+func readStruct(r io.ReaderAt, offset int64, data any) error {
+	return binary.Read(io.NewSectionReader(r, offset, int64(binary.Size(data))), binary.LittleEndian, data)
+}
+
+// readMinidumpMemoryList reads MINIDUMP_MEMORY_LIST, where each range has its own RVA
+func readMinidumpMemoryList(r io.ReaderAt, dir minidumpDirectory, size int64) ([]minidumpRange, error) {
+	var count uint32
+	if err := readStruct(r, int64(dir.Rva), &count); err != nil {
+		return nil, fmt.Errorf("failed to read memory list: %w", err)
+	}
+	if dir.DataSize < 4 || count > (dir.DataSize-4)/16 {
+		return nil, errors.New("truncated memory list")
+	}
+
+	descriptors := make([]minidumpMemoryDescriptor, count)
+	if err := readStruct(r, int64(dir.Rva)+4, descriptors); err != nil {
+		return nil, fmt.Errorf("failed to read memory list: %w", err)
+	}
+
+	ranges := make([]minidumpRange, count)
+	for i, desc := range descriptors {
+		ranges[i] = minidumpRange{
+			start:  desc.StartOfMemoryRange,
+			size:   uint64(desc.DataSize),
+			offset: int64(desc.Rva),
+		}
+		if err := checkMinidumpRange(ranges[i], size); err != nil {
+			return nil, err
+		}
+	}
+	return ranges, nil
+}
+
+// readMinidumpMemory64List reads MINIDUMP_MEMORY64_LIST, whose ranges are stored back to back from BaseRva
+func readMinidumpMemory64List(r io.ReaderAt, dir minidumpDirectory, size int64) ([]minidumpRange, error) {
+	var list struct {
+		NumberOfMemoryRanges uint64
+		BaseRva              uint64
+	}
+	if err := readStruct(r, int64(dir.Rva), &list); err != nil {
+		return nil, fmt.Errorf("failed to read memory64 list: %w", err)
+	}
+	if dir.DataSize < 16 || list.NumberOfMemoryRanges > uint64(dir.DataSize-16)/16 {
+		return nil, errors.New("truncated memory64 list")
+	}
+
+	descriptors := make([]minidumpMemoryDescriptor64, list.NumberOfMemoryRanges)
+	if err := readStruct(r, int64(dir.Rva)+16, descriptors); err != nil {
+		return nil, fmt.Errorf("failed to read memory64 list: %w", err)
+	}
+
+	ranges := make([]minidumpRange, len(descriptors))
+	offset := int64(list.BaseRva)
+	for i, desc := range descriptors {
+		ranges[i] = minidumpRange{
+			start:  desc.StartOfMemoryRange,
+			size:   desc.DataSize,
+			offset: offset,
+		}
+		if err := checkMinidumpRange(ranges[i], size); err != nil {
+			return nil, err
+		}
+		offset += int64(desc.DataSize)
+	}
+	return ranges, nil
+}
+
+// checkMinidumpRange checks that the data of a captured range lies in the file
+// of size bytes and that the range does not wrap around the address space
+func checkMinidumpRange(rng minidumpRange, size int64) error {
+	if rng.offset < 0 || rng.offset > size || rng.size > uint64(size-rng.offset) {
+		return fmt.Errorf("memory range at 0x%X lies outside the file", rng.start)
+	}
+	if rng.start+rng.size < rng.start {
+		return fmt.Errorf("memory range at 0x%X overflows the address space", rng.start)
+	}
+	return nil
+}
+
+// readMinidumpMemoryInfoList reads MINIDUMP_MEMORY_INFO_LIST
+func readMinidumpMemoryInfoList(r io.ReaderAt, dir minidumpDirectory) ([]minidumpMemoryInfo, error) {
+	var list minidumpMemoryInfoListHeader
+	if err := readStruct(r, int64(dir.Rva), &list); err != nil {
+		return nil, fmt.Errorf("failed to read memory info list: %w", err)
+	}
+
+	entrySize := uint64(binary.Size(minidumpMemoryInfo{}))
+	if uint64(list.SizeOfEntry) < entrySize {
+		return nil, fmt.Errorf("unsupported memory info entry size %d", list.SizeOfEntry)
+	}
+	if list.SizeOfHeader > dir.DataSize || list.NumberOfEntries > uint64(dir.DataSize-list.SizeOfHeader)/uint64(list.SizeOfEntry) {
+		return nil, errors.New("truncated memory info list")
+	}
+
+	infos := make([]minidumpMemoryInfo, list.NumberOfEntries)
+	for i := range infos {
+		offset := int64(dir.Rva) + int64(list.SizeOfHeader) + int64(i)*int64(list.SizeOfEntry)
+		if err := readStruct(r, offset, &infos[i]); err != nil {
+			return nil, fmt.Errorf("failed to read memory info list: %w", err)
+		}
+	}
+	return infos, nil
+}
+
+// readMinidumpModuleList reads MINIDUMP_MODULE_LIST and the module names
+func readMinidumpModuleList(r io.ReaderAt, dir minidumpDirectory) ([]minidumpModuleInfo, error) {
+	var count uint32
+	if err := readStruct(r, int64(dir.Rva), &count); err != nil {
+		return nil, fmt.Errorf("failed to read module list: %w", err)
+	}
+	if dir.DataSize < 4 || count > (dir.DataSize-4)/minidumpModuleSize {
+		return nil, errors.New("truncated module list")
+	}
+
+	modules := make([]minidumpModuleInfo, count)
+	for i := range modules {
+		var module minidumpModule
+		if err := readStruct(r, int64(dir.Rva)+4+int64(i)*minidumpModuleSize, &module); err != nil {
+			return nil, fmt.Errorf("failed to read module list: %w", err)
+		}
+
+		name, err := readMinidumpString(r, int64(module.ModuleNameRva))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read module name: %w", err)
+		}
+
+		modules[i] = minidumpModuleInfo{
+			base: module.BaseOfImage,
+			size: uint64(module.SizeOfImage),
+			name: name,
+		}
+	}
+	return modules, nil
+}
+
+// readMinidumpString reads a MINIDUMP_STRING: a byte length followed by UTF-16LE characters
+func readMinidumpString(r io.ReaderAt, offset int64) (string, error) {
+	var length uint32
+	if err := readStruct(r, offset, &length); err != nil {
+		return "", err
+	}
+	if length%2 != 0 || length > 0x10000 {
+		return "", fmt.Errorf("invalid string length %d", length)
+	}
+
+	chars := make([]uint16, length/2)
+	if err := readStruct(r, offset+4, chars); err != nil {
+		return "", err
+	}
+	return string(utf16.Decode(chars)), nil
+}
+
+// mergeMinidumpRanges sorts the captured ranges and drops ranges captured twice,
+// which happens when a dump has both a MemoryList and a Memory64List
+func mergeMinidumpRanges(ranges []minidumpRange) []minidumpRange {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start < ranges[j].start
+	})
+
+	merged := ranges[:0]
+	var end uint64
+	for _, rng := range ranges {
+		if rng.size == 0 {
+			continue
+		}
+		if len(merged) > 0 && rng.start < end {
+			// Keep the part that is not already covered
+			if rng.start+rng.size <= end {
+				continue
+			}
+			skip := end - rng.start
+			rng.start += skip
+			rng.size -= skip
+			rng.offset += int64(skip)
+		}
+		merged = append(merged, rng)
+		end = rng.start + rng.size
+	}
+	return merged
+}
+
+// buildMinidumpSegments turns the captured ranges into segments. With a memory info
+// list every allocated region is reported with its attributes and split where the
+// captured data starts or ends; without one each captured range is a readable region.
+func buildMinidumpSegments(r io.ReaderAt, ranges []minidumpRange, infos []minidumpMemoryInfo,
+	modules []minidumpModuleInfo) []segment {
+
+	moduleName := func(addr uint64) (string, bool) {
+		for _, module := range modules {
+			if module.base <= addr && addr < module.base+module.size {
+				return module.name, true
+			}
+		}
+		return "", false
+	}
+
+	var segments []segment
+	if len(infos) == 0 {
+		for _, rng := range ranges {
+			region := Region{
+				BaseAddress: Address(rng.start),
+				Size:        rng.size,
+				Protection:  ProtectRead,
+				State:       StateCommit,
+			}
+			if name, ok := moduleName(rng.start); ok {
+				region.Name = name
+				region.Type = TypeImage
+			}
+			segments = append(segments, segment{region: region, reader: r, offset: rng.offset, dataSize: rng.size})
+		}
+		return segments
+	}
+
+	for _, info := range infos {
+		if info.State != winMemCommit && info.State != winMemReserve {
+			continue
+		}
+
+		template := Region{
+			Protection: protectionFromWindows(info.Protect),
+			State:      stateFromWindows(info.State),
+			Type:       typeFromWindows(info.Type),
+		}
+		if name, ok := moduleName(info.BaseAddress); ok {
+			template.Name = name
+		}
+
+		// Split the region into captured and missing pieces
+		address := info.BaseAddress
+		end := info.BaseAddress + info.RegionSize
+		for address < end {
+			piece := template
+			piece.BaseAddress = Address(address)
+
+			i := sort.Search(len(ranges), func(i int) bool {
+				return ranges[i].start+ranges[i].size > address
+			})
+			if i < len(ranges) && ranges[i].start <= address {
+				rng := ranges[i]
+				piece.Size = min(end, rng.start+rng.size) - address
+				segments = append(segments, segment{
+					region:   piece,
+					reader:   r,
+					offset:   rng.offset + int64(address-rng.start),
+					dataSize: piece.Size,
+				})
+			} else {
+				next := end
+				if i < len(ranges) {
+					next = min(end, ranges[i].start)
+				}
+				piece.Size = next - address
+				segments = append(segments, segment{region: piece})
+			}
+			address += piece.Size
+		}
+	}
+
+	return segments
+}
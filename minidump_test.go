@@ -0,0 +1,282 @@
+package memoryscanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"unicode/utf16"
+)
+
+// 测试用的内存范围
+type testDumpRange struct {
+	start uint64
+	data  []byte
+}
+
+// 辅助函数：构造包含模块、内存信息和内存数据的 minidump 文件
+func writeTestMinidump(t *testing.T, modules []minidumpModuleInfo, infos []minidumpMemoryInfo,
+	memory64 []testDumpRange, memory []testDumpRange) string {
+	t.Helper()
+
+	const streamCount = 4
+	const directoryRva = 32
+	offset := uint32(directoryRva + streamCount*12)
+	var body bytes.Buffer
+	write := func(data any) {
+		if err := binary.Write(&body, binary.LittleEndian, data); err != nil {
+			t.Fatalf("binary.Write failed: %v", err)
+		}
+	}
+	rva := func() uint32 { return offset + uint32(body.Len()) }
+
+	// 模块名字符串
+	nameRvas := make([]uint32, len(modules))
+	for i, module := range modules {
+		nameRvas[i] = rva()
+		chars := utf16.Encode([]rune(module.name))
+		write(uint32(len(chars) * 2))
+		write(chars)
+	}
+
+	// ModuleListStream
+	moduleRva := rva()
+	write(uint32(len(modules)))
+	for i, module := range modules {
+		write(minidumpModule{BaseOfImage: module.base, SizeOfImage: uint32(module.size), ModuleNameRva: nameRvas[i]})
+		write(make([]byte, minidumpModuleSize-binary.Size(minidumpModule{})))
+	}
+	moduleSize := rva() - moduleRva
+
+	// MemoryInfoListStream
+	infoRva := rva()
+	write(minidumpMemoryInfoListHeader{SizeOfHeader: 16, SizeOfEntry: 48, NumberOfEntries: uint64(len(infos))})
+	write(infos)
+	infoSize := rva() - infoRva
+
+	// MemoryListStream，每个范围的数据紧跟在描述符之后
+	memoryRva := rva()
+	dataRva := memoryRva + 4 + uint32(len(memory))*16
+	write(uint32(len(memory)))
+	for _, rng := range memory {
+		write(minidumpMemoryDescriptor{StartOfMemoryRange: rng.start, DataSize: uint32(len(rng.data)), Rva: dataRva})
+		dataRva += uint32(len(rng.data))
+	}
+	memorySize := rva() - memoryRva
+	for _, rng := range memory {
+		write(rng.data)
+	}
+
+	// Memory64ListStream，数据从 BaseRva 开始连续存放
+	memory64Rva := rva()
+	write(uint64(len(memory64)))
+	write(uint64(memory64Rva + 16 + uint32(len(memory64))*16))
+	for _, rng := range memory64 {
+		write(minidumpMemoryDescriptor64{StartOfMemoryRange: rng.start, DataSize: uint64(len(rng.data))})
+	}
+	memory64Size := rva() - memory64Rva
+	for _, rng := range memory64 {
+		write(rng.data)
+	}
+
+	var file bytes.Buffer
+	if err := binary.Write(&file, binary.LittleEndian, minidumpHeader{
+		Signature:          minidumpSignature,
+		Version:            0xA793,
+		NumberOfStreams:    streamCount,
+		StreamDirectoryRva: directoryRva,
+	}); err != nil {
+		t.Fatalf("binary.Write failed: %v", err)
+	}
+	if err := binary.Write(&file, binary.LittleEndian, []minidumpDirectory{
+		{StreamType: minidumpModuleListStream, DataSize: moduleSize, Rva: moduleRva},
+		{StreamType: minidumpMemoryInfoListStream, DataSize: infoSize, Rva: infoRva},
+		{StreamType: minidumpMemoryListStream, DataSize: memorySize, Rva: memoryRva},
+		{StreamType: minidumpMemory64ListStream, DataSize: memory64Size, Rva: memory64Rva},
+	}); err != nil {
+		t.Fatalf("binary.Write failed: %v", err)
+	}
+	file.Write(body.Bytes())
+
+	path := filepath.Join(t.TempDir(), "WeChatAppEx.dmp")
+	if err := os.WriteFile(path, file.Bytes(), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestMinidumpSource(t *testing.T) {
+	const moduleName = `C:\Program Files\Tencent\WeChat\WeChatAppEx.exe`
+	path := writeTestMinidump(t,
+		[]minidumpModuleInfo{{base: 0x20000, size: 0x1000, name: moduleName}},
+		[]minidumpMemoryInfo{
+			// 只有前半部分被转储的堆区域
+			{BaseAddress: 0x10000, RegionSize: 0x2000, State: winMemCommit, Protect: winPageReadWrite, Type: winMemPrivate},
+			{BaseAddress: 0x20000, RegionSize: 0x1000, State: winMemCommit, Protect: winPageExecuteRead, Type: winMemImage},
+			// 不可访问的区域即使被转储也不应被扫描
+			{BaseAddress: 0x30000, RegionSize: 0x1000, State: winMemCommit, Protect: winPageNoAccess, Type: winMemPrivate},
+			{BaseAddress: 0x40000, RegionSize: 0x1000, State: winMemFree, Protect: winPageNoAccess},
+			{BaseAddress: 0x50000, RegionSize: 0x1000, State: winMemReserve, Type: winMemPrivate},
+		},
+		[]testDumpRange{
+			{start: 0x10000, data: makeRegionData(0x1000, map[int]string{0x100: "WeChat"})},
+			{start: 0x20000, data: makeRegionData(0x1000, map[int]string{0x10: "WeChat"})},
+			{start: 0x30000, data: makeRegionData(0x1000, map[int]string{0x0: "WeChat"})},
+		},
+		// 与 Memory64List 重叠的 MemoryList 范围
+		[]testDumpRange{{start: 0x10800, data: makeRegionData(0x100, nil)}},
+	)
+
+	source, err := OpenMinidump(path)
+	if err != nil {
+		t.Fatalf("OpenMinidump failed: %v", err)
+	}
+	scanner := NewScannerFromSource(source)
+	defer scanner.Close()
+
+	regions, err := source.Regions(context.Background())
+	if err != nil {
+		t.Fatalf("Regions failed: %v", err)
+	}
+
+	expected := []Region{
+		{BaseAddress: 0x10000, Size: 0x1000, Protection: ProtectRead | ProtectWrite, Type: TypePrivate},
+		{BaseAddress: 0x11000, Size: 0x1000, Protection: ProtectRead | ProtectWrite, Type: TypePrivate},
+		{BaseAddress: 0x20000, Size: 0x1000, Protection: ProtectRead | ProtectExecute, Type: TypeImage, Name: moduleName},
+		{BaseAddress: 0x30000, Size: 0x1000, Type: TypePrivate},
+		{BaseAddress: 0x50000, Size: 0x1000, State: StateReserve, Type: TypePrivate},
+	}
+	if !slices.Equal(regions, expected) {
+		t.Errorf("Regions() = %+v, want %+v", regions, expected)
+	}
+
+	result := scanAddresses(t, scanner, ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		MaxAddress: 0x7FFFFFFFFFFF,
+	})
+	if want := []Address{0x10100, 0x20010}; !slices.Equal(result, want) {
+		t.Errorf("Scan addresses = %v, want %v", result, want)
+	}
+
+	// 未转储的部分不可读
+	if _, err := source.ReadAt(make([]byte, 16), 0x11000); err == nil {
+		t.Error("ReadAt(0x11000) succeeded, want error")
+	}
+}
+
+func TestMinidumpSourceWithoutMemoryInfo(t *testing.T) {
+	path := writeTestMinidump(t, nil, nil,
+		[]testDumpRange{{start: 0x10000, data: makeRegionData(0x1000, map[int]string{0x200: "WeChat"})}},
+		nil,
+	)
+
+	source, err := OpenMinidump(path)
+	if err != nil {
+		t.Fatalf("OpenMinidump failed: %v", err)
+	}
+	scanner := NewScannerFromSource(source)
+	defer scanner.Close()
+
+	result := scanAddresses(t, scanner, ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		MaxAddress: 0x7FFFFFFFFFFF,
+	})
+	if want := []Address{0x10200}; !slices.Equal(result, want) {
+		t.Errorf("Scan addresses = %v, want %v", result, want)
+	}
+}
+
+func TestOpenMinidumpInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.dmp")
+	if err := os.WriteFile(path, []byte("not a minidump at all, just text"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if _, err := OpenMinidump(path); err == nil {
+		t.Error("OpenMinidump on an invalid file succeeded, want error")
+	}
+}
+
+func TestOpenMinidumpMalformed(t *testing.T) {
+	// 流目录中各流的位置：模块、内存信息、MemoryList、Memory64List
+	const directoryRva = 32
+	streamRva := func(data []byte, stream int) int {
+		return int(binary.LittleEndian.Uint32(data[directoryRva+stream*12+8:]))
+	}
+
+	tests := []struct {
+		name  string
+		patch func(data []byte) []byte
+	}{
+		{"memory64 count overflows", func(data []byte) []byte {
+			binary.LittleEndian.PutUint64(data[streamRva(data, 3):], 1<<60)
+			return data
+		}},
+		{"memory64 count too large", func(data []byte) []byte {
+			binary.LittleEndian.PutUint64(data[streamRva(data, 3):], 2)
+			return data
+		}},
+		{"memory64 range past end of file", func(data []byte) []byte {
+			binary.LittleEndian.PutUint64(data[streamRva(data, 3)+16+8:], 1<<40)
+			return data
+		}},
+		{"memory64 data past end of file", func(data []byte) []byte {
+			binary.LittleEndian.PutUint64(data[streamRva(data, 3)+8:], 1<<62)
+			return data
+		}},
+		{"memory range past end of file", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[streamRva(data, 2)+4+12:], 0xFFFFFF00)
+			return data
+		}},
+		{"memory count too large", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[streamRva(data, 2):], 0xFFFFFFFF)
+			return data
+		}},
+		{"memory info count overflows", func(data []byte) []byte {
+			binary.LittleEndian.PutUint64(data[streamRva(data, 1)+8:], 1<<60)
+			return data
+		}},
+		{"memory info header too large", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[streamRva(data, 1):], 0xFFFFFFFF)
+			return data
+		}},
+		{"module count too large", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[streamRva(data, 0):], 0xFFFFFFFF)
+			return data
+		}},
+		{"stream past end of file", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[directoryRva+3*12+4:], 0xFFFFFFF0)
+			return data
+		}},
+		{"truncated file", func(data []byte) []byte {
+			return data[:len(data)-0x800]
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestMinidump(t,
+				[]minidumpModuleInfo{{base: 0x20000, size: 0x1000, name: "WeChatAppEx.exe"}},
+				[]minidumpMemoryInfo{{BaseAddress: 0x10000, RegionSize: 0x1000, State: winMemCommit, Protect: winPageReadWrite, Type: winMemPrivate}},
+				[]testDumpRange{{start: 0x10000, data: makeRegionData(0x1000, nil)}},
+				[]testDumpRange{{start: 0x30000, data: makeRegionData(0x100, nil)}},
+			)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile failed: %v", err)
+			}
+			if err := os.WriteFile(path, tt.patch(data), 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+
+			// 损坏的文件返回错误而不是崩溃
+			if source, err := OpenMinidump(path); err == nil {
+				source.Close()
+				t.Error("OpenMinidump on a malformed file succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package memoryscanner
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// scanMatcher finds the matches of a scan's patterns in the data read from a region.
+// It is shared by the workers of a scan, so find must be safe for concurrent use.
+type scanMatcher interface {
+	// maxLength returns the length of the longest match of any pattern
+	maxLength() int
+	// find returns the matches starting at from or later in data, in ascending
+	// order of offset. The bytes before from are only context.
+	find(data []byte, from int) []patternMatch
+}
+
+// patternMatch is a match found by a scanMatcher
+type patternMatch struct {
+	offset int
+	length int
+	// patterns holds the names of the patterns matching at offset, nil for a
+	// scan of a single pattern
+	patterns []string
+	// captures holds the start and end offsets of the capture groups of a
+	// regular expression, -1 for groups that did not take part in the match
+	captures []int
+}
+
+// newScanMatcher compiles the patterns of the scan options
+func newScanMatcher(opts ScanOptions) (scanMatcher, error) {
+	if opts.Regexp != "" {
+		if opts.Pattern != "" || len(opts.Patterns) > 0 {
+			return nil, errors.New("Regexp cannot be combined with Pattern or Patterns")
+		}
+		return newRegexpMatcher(opts)
+	}
+
+	if len(opts.Patterns) == 0 {
+		matcher, err := NewPatternMatcher(opts.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return singleMatcher{matcher: matcher, ignoreCase: opts.IgnoreCase}, nil
+	}
+
+	if opts.Pattern != "" {
+		return nil, errors.New("Pattern and Patterns cannot both be set")
+	}
+	return newMultiMatcher(opts.Patterns, opts.IgnoreCase)
+}
+
+// singleMatcher is the scanMatcher of ScanOptions.Pattern
+type singleMatcher struct {
+	matcher    *PatternMatcher
+	ignoreCase bool
+}
+
+// maxLength returns the length of the longest match of the pattern
+func (m singleMatcher) maxLength() int {
+	return m.matcher.GetMaxPatternLength()
+}
+
+// find returns the matches of the pattern in data[from:]
+func (m singleMatcher) find(data []byte, from int) []patternMatch {
+	var matches []patternMatch
+	m.matcher.find(data[from:], m.ignoreCase, func(start, end int) {
+		matches = append(matches, patternMatch{offset: from + start, length: end - start})
+	})
+	return matches
+}
+
+// multiMatcher finds several patterns in one pass over the data. The anchors
+// of the patterns, their longest runs of exact bytes, are compiled into an
+// Aho-Corasick automaton; every anchor it finds gives a candidate position
+// where the whole pattern is then compared. Patterns without exact bytes are
+// compared at every position.
+type multiMatcher struct {
+	names      []string
+	matchers   []*PatternMatcher
+	ignoreCase bool
+	// wildcards holds the patterns without an anchor
+	wildcards []int
+	// classes maps each byte to its column in the transition table. Bytes that
+	// occur in no anchor share column 0, and with ignoreCase both cases of a
+	// letter share a column, which keeps the table small enough for the cache.
+	classes [256]byte
+	stride  int
+	// next is the transition table of the automaton, row by row. Transitions
+	// hold the offset of the target row rather than the state number, and
+	// state 0 is the root.
+	next []int32
+	// outputs holds the patterns whose anchor ends in each state
+	outputs [][]int
+	// final marks the rows of the states with outputs
+	final []bool
+	// starts marks the bytes leaving the root, the bytes outside it are skipped
+	// without following the automaton
+	starts  [256]bool
+	longest int
+}
+
+// newMultiMatcher compiles the named patterns into one automaton
+func newMultiMatcher(patterns []NamedPattern, ignoreCase bool) (*multiMatcher, error) {
+	m := &multiMatcher{
+		names:      make([]string, len(patterns)),
+		matchers:   make([]*PatternMatcher, len(patterns)),
+		ignoreCase: ignoreCase,
+		outputs:    make([][]int, 1),
+	}
+
+	for i, pattern := range patterns {
+		matcher, err := NewPatternMatcher(pattern.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", pattern.Name, err)
+		}
+		m.names[i] = pattern.Name
+		m.matchers[i] = matcher
+		m.longest = max(m.longest, matcher.GetMaxPatternLength())
+		if matcher.anchor == nil {
+			m.wildcards = append(m.wildcards, i)
+		}
+	}
+
+	// Give every byte of an anchor its own column
+	m.stride = 1
+	for _, matcher := range m.matchers {
+		if matcher.anchor == nil {
+			continue
+		}
+		for _, b := range matcher.anchor.literal {
+			if ignoreCase {
+				b = foldTable[b]
+			}
+			if m.classes[b] == 0 {
+				m.classes[b] = byte(m.stride)
+				m.stride++
+			}
+		}
+	}
+	if ignoreCase {
+		for b := 'a'; b <= 'z'; b++ {
+			m.classes[b] = m.classes[b-'a'+'A']
+		}
+	}
+	m.next = make([]int32, m.stride)
+
+	// Build the trie of the anchors
+	for i, matcher := range m.matchers {
+		if matcher.anchor == nil {
+			continue
+		}
+		state := int32(0)
+		for _, b := range matcher.anchor.literal {
+			column := int32(m.classes[b])
+			if m.next[state*int32(m.stride)+column] == 0 {
+				m.next = append(m.next, make([]int32, m.stride)...)
+				m.outputs = append(m.outputs, nil)
+				m.next[state*int32(m.stride)+column] = int32(len(m.outputs) - 1)
+			}
+			state = m.next[state*int32(m.stride)+column]
+		}
+		m.outputs[state] = append(m.outputs[state], i)
+	}
+
+	// Turn the trie into a complete automaton breadth first: a missing
+	// transition follows the failure link, the longest proper suffix of the
+	// state that is also in the trie, and each state inherits its outputs
+	fail := make([]int32, len(m.outputs))
+	queue := make([]int32, 0, len(m.outputs))
+	for column := range m.stride {
+		if child := m.next[column]; child != 0 {
+			queue = append(queue, child)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		m.outputs[state] = append(m.outputs[state], m.outputs[fail[state]]...)
+		row := m.next[int(state)*m.stride : int(state+1)*m.stride]
+		failRow := m.next[int(fail[state])*m.stride : int(fail[state]+1)*m.stride]
+		for column, child := range row {
+			if child == 0 {
+				row[column] = failRow[column]
+				continue
+			}
+			fail[child] = failRow[column]
+			queue = append(queue, child)
+		}
+	}
+
+	for b := range 256 {
+		m.starts[b] = m.next[m.classes[b]] != 0
+	}
+	m.final = make([]bool, len(m.next))
+	for i := range m.next {
+		m.final[i/m.stride*m.stride] = len(m.outputs[i/m.stride]) > 0
+		m.next[i] *= int32(m.stride)
+	}
+	return m, nil
+}
+
+// maxLength returns the length of the longest pattern
+func (m *multiMatcher) maxLength() int {
+	return m.longest
+}
+
+// find returns the positions in data[from:] where at least one pattern matches.
+// Each match is as long as the longest match of a pattern there and names every
+// one of the patterns, in the order of ScanOptions.Patterns.
+func (m *multiMatcher) find(data []byte, from int) []patternMatch {
+	type hit struct{ offset, end, pattern int }
+	var hits []hit
+	states := make([]programState, len(m.matchers))
+	data = data[from:]
+
+	row := int32(0)
+	for i := 0; i < len(data); i++ {
+		if row == 0 {
+			for i < len(data) && !m.starts[data[i]] {
+				i++
+			}
+			if i == len(data) {
+				break
+			}
+		}
+		row = m.next[row+int32(m.classes[data[i]])]
+		if !m.final[row] {
+			continue
+		}
+		for _, p := range m.outputs[int(row)/m.stride] {
+			matcher := m.matchers[p]
+			anchor := matcher.anchor
+			offset := i + 1 - len(anchor.literal) - anchor.offset
+			if offset < 0 || offset+matcher.minLength > len(data) {
+				continue
+			}
+			if end, ok := matcher.matchAt(data, offset, m.ignoreCase, &states[p]); ok {
+				hits = append(hits, hit{offset: offset, end: end, pattern: p})
+			}
+		}
+	}
+	for _, p := range m.wildcards {
+		for offset := 0; offset+m.matchers[p].minLength <= len(data); offset++ {
+			if end, ok := m.matchers[p].matchAt(data, offset, m.ignoreCase, &states[p]); ok {
+				hits = append(hits, hit{offset: offset, end: end, pattern: p})
+			}
+		}
+	}
+	if len(hits) == 0 {
+		return nil
+	}
+
+	// Anchors end in a different order than the patterns start, so group
+	// the hits by offset
+	slices.SortFunc(hits, func(a, b hit) int {
+		if a.offset != b.offset {
+			return a.offset - b.offset
+		}
+		return a.pattern - b.pattern
+	})
+
+	var matches []patternMatch
+	for i := 0; i < len(hits); {
+		offset := hits[i].offset
+		match := patternMatch{offset: from + offset}
+		for ; i < len(hits) && hits[i].offset == offset; i++ {
+			match.length = max(match.length, hits[i].end-offset)
+			match.patterns = append(match.patterns, m.names[hits[i].pattern])
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
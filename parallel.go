@@ -0,0 +1,255 @@
+package memoryscanner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scanBatchSize is the number of matches a worker collects before handing them
+// to the goroutine running Scan
+const scanBatchSize = 256
+
+// scanEvent is a match or an unreadable range found by a worker
+type scanEvent struct {
+	match Match
+	// hole is set when the event reports an unreadable range
+	hole AddressRange
+}
+
+// scanParallel scans the address ranges with opts.Workers goroutines. Workers
+// take ranges in ascending order and send their results in batches; the calling
+// goroutine passes them to the handlers, either as they arrive or, with
+// opts.Ordered, range by range. Stopping the handler or cancelling ctx stops
+// all workers, and scanParallel returns only after every worker has exited.
+func (s *Scanner) scanParallel(ctx context.Context, ranges []scanRange,
+	matcher scanMatcher, opts ScanOptions, stats *ScanStats, progress *progressReporter) error {
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := min(opts.Workers, len(ranges))
+
+	// Ordered scans give every range its own channel so results can be taken
+	// in range order; a worker running ahead blocks until its range is reached
+	var shared chan []scanEvent
+	var outputs []chan []scanEvent
+	if opts.Ordered {
+		outputs = make([]chan []scanEvent, len(ranges))
+		for i := range outputs {
+			outputs[i] = make(chan []scanEvent, 1)
+		}
+	} else {
+		shared = make(chan []scanEvent, workers)
+	}
+
+	// The first read error of any worker, such as the process exiting, ends the scan
+	var failOnce sync.Once
+	var failure error
+	fail := func(err error) {
+		failOnce.Do(func() {
+			failure = err
+			cancel()
+		})
+	}
+
+	budget := newScanBudget(opts, workers, readMargin(opts, matcher))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	scanWorkers := make([]*scanWorker, workers)
+	for i := range scanWorkers {
+		worker := newScanWorker(ctx, s.source, matcher, opts, budget, progress)
+		worker.fail = fail
+		scanWorkers[i] = worker
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(ranges) || ctx.Err() != nil {
+					return
+				}
+
+				out := shared
+				if opts.Ordered {
+					out = outputs[i]
+				}
+				worker.scanRange(ranges[i], out)
+				if opts.Ordered {
+					close(out)
+				}
+			}
+		}()
+	}
+
+	// deliver passes a batch to the handlers, returning false to stop the scan
+	deliver := func(events []scanEvent) bool {
+		for _, event := range events {
+			if event.hole.Size() > 0 {
+				opts.UnreadableHandler(event.hole)
+				continue
+			}
+
+			// Check if context was cancelled
+			select {
+			case <-ctx.Done():
+				return false
+			default:
+			}
+
+			stats.MatchesDelivered++
+			if !opts.Handler(event.match) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Progress is reported on a timer since deliveries may be rare
+	var tick <-chan time.Time
+	if progress != nil {
+		ticker := time.NewTicker(progress.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	stopped := false
+	if opts.Ordered {
+	delivery:
+		for _, out := range outputs {
+			for {
+				select {
+				case events, ok := <-out:
+					if !ok {
+						continue delivery
+					}
+					if !deliver(events) {
+						stopped = true
+						break delivery
+					}
+				case <-tick:
+					progress.report(stats.MatchesDelivered, true)
+				case <-ctx.Done():
+					break delivery
+				}
+			}
+		}
+		cancel()
+		wg.Wait()
+	} else {
+		go func() {
+			wg.Wait()
+			close(shared)
+		}()
+
+		// After a stop the channel is drained so blocked workers can exit
+	results:
+		for {
+			select {
+			case events, ok := <-shared:
+				if !ok {
+					break results
+				}
+				if !stopped && !deliver(events) {
+					stopped = true
+					cancel()
+				}
+			case <-tick:
+				if !stopped {
+					progress.report(stats.MatchesDelivered, true)
+				}
+			}
+		}
+	}
+
+	for _, worker := range scanWorkers {
+		stats.add(worker.scanner.stats)
+	}
+	if failure != nil {
+		return failure
+	}
+	if err := parent.Err(); err != nil {
+		return err
+	}
+	if progress != nil && !stopped {
+		progress.report(stats.MatchesDelivered, true)
+	}
+	return nil
+}
+
+// scanWorker scans ranges on a worker goroutine, collecting the matches and
+// unreadable ranges into batches for the goroutine running Scan
+type scanWorker struct {
+	ctx     context.Context
+	scanner regionScanner
+	batch   []scanEvent
+	out     chan<- []scanEvent
+	// fail stops the whole scan with an error
+	fail func(err error)
+}
+
+// newScanWorker creates a worker whose handlers add to its batch
+func newScanWorker(ctx context.Context, source MemorySource, matcher scanMatcher,
+	opts ScanOptions, budget *scanBudget, progress *progressReporter) *scanWorker {
+
+	w := &scanWorker{ctx: ctx}
+
+	workerOpts := opts
+	// Batches outlive the read buffers, so match data is always copied
+	workerOpts.ReuseMatchData = false
+	workerOpts.Handler = func(match Match) bool {
+		w.batch = append(w.batch, scanEvent{match: match})
+		return true
+	}
+	if opts.UnreadableHandler != nil {
+		workerOpts.UnreadableHandler = func(hole AddressRange) {
+			w.batch = append(w.batch, scanEvent{hole: hole})
+		}
+	}
+
+	w.scanner = regionScanner{
+		source:   source,
+		matcher:  matcher,
+		opts:     workerOpts,
+		budget:   budget,
+		progress: progress,
+		// Batches are only sent between chunks, so a worker blocked on a full
+		// channel never holds a read buffer another worker is waiting for
+		afterChunk: func() bool {
+			return len(w.batch) < scanBatchSize || w.send()
+		},
+	}
+	return w
+}
+
+// scanRange scans one address range, sending its results to out
+func (w *scanWorker) scanRange(r scanRange, out chan<- []scanEvent) {
+	w.out = out
+	err := w.scanner.scanRegion(w.ctx, r)
+	switch {
+	case err == nil:
+		w.send()
+	case !errors.Is(err, errStopScan) && w.ctx.Err() == nil:
+		w.fail(err)
+	}
+	w.batch = nil
+}
+
+// send passes the current batch to the goroutine running Scan, returning false
+// if the scan was stopped first
+func (w *scanWorker) send() bool {
+	if len(w.batch) == 0 {
+		return true
+	}
+	select {
+	case w.out <- w.batch:
+		w.batch = nil
+		return true
+	case <-w.ctx.Done():
+		return false
+	}
+}
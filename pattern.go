@@ -1,10 +1,11 @@
 package memoryscanner
 
 import (
-	"encoding/hex"
-	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // StringToPattern converts a search string to an AOB (Array of Bytes) pattern.
@@ -41,89 +42,680 @@ func StringToPattern(searchStr string, minLength int) string {
 	return builder.String()
 }
 
+// maxPatternJump is the longest jump a pattern may contain
+const maxPatternJump = 64 << 10
+
+// MatchSpan is the part of the data searched by FindSpans that a match covers
+type MatchSpan struct {
+	Start int
+	End   int
+}
+
 // PatternMatcher handles pattern matching logic
 type PatternMatcher struct {
-	patternBytes  []byte
-	wildcardMask  []bool
-	patternLength int
+	// patternBytes holds the bytes at fixed offsets from the start of a match,
+	// with the bits outside their mask cleared. For patterns without jumps and
+	// groups this is the whole pattern.
+	patternBytes []byte
+	// masks selects the bits of each of patternBytes that are compared: 0xFF
+	// for an exact byte, 0x00 for a wildcard
+	masks []byte
+	// program is the compiled pattern, nil if comparing patternBytes is enough
+	program []patternInst
+	// memoRows is the number of instructions of program whose results are
+	// remembered during a search
+	memoRows  int
+	minLength int
+	maxLength int
+	// anchor finds the candidate positions, nil if patternBytes has no exact byte
+	anchor *patternAnchor
+}
+
+// patternElementKind is the kind of a pattern element
+type patternElementKind int
+
+const (
+	// elementByte matches one byte by value and mask
+	elementByte patternElementKind = iota
+	// elementJump skips between min and max bytes
+	elementJump
+	// elementGroup matches any one of its alternatives
+	elementGroup
+)
+
+// patternElement is a byte, jump or alternation group of a pattern
+type patternElement struct {
+	kind         patternElementKind
+	value        byte
+	mask         byte
+	min          int
+	max          int
+	alternatives [][]patternElement
 }
 
-// NewPatternMatcher creates a new pattern matcher from an AOB pattern string
+// NewPatternMatcher creates a new pattern matcher from an AOB pattern string.
+// The pattern is a whitespace-separated list of bytes, each one of:
+//   - a hex byte such as 4C, matching exactly that byte
+//   - ?? matching any byte
+//   - a hex digit and ?, such as 4? or ?F, matching any value of the ? nibble
+//   - value&mask such as E8&F0, matching bytes b where b&mask == value&mask
+//
+// A jump such as [2-8] skips between 2 and 8 bytes, [4] exactly 4 bytes. A
+// group such as (48|4C 8B) matches any one of its alternatives, which may
+// differ in length.
 func NewPatternMatcher(pattern string) (*PatternMatcher, error) {
-	parts := strings.Fields(pattern)
-	if len(parts) == 0 {
-		return nil, errors.New("empty pattern")
+	p := patternParser{pattern: pattern, tokens: splitPattern(pattern)}
+	if len(p.tokens) == 0 {
+		return nil, &PatternError{Pattern: pattern, Msg: "empty pattern"}
 	}
 
-	patternBytes := make([]byte, len(parts))
-	wildcardMask := make([]bool, len(parts))
+	elements, err := p.parseSequence()
+	if err != nil {
+		return nil, err
+	}
+	if p.next < len(p.tokens) {
+		token := p.tokens[p.next]
+		return nil, p.errorAt(token, "unexpected "+token.text+" outside a group")
+	}
 
-	for i, part := range parts {
-		if part == "??" {
-			wildcardMask[i] = true
-		} else {
-			decoded, err := hex.DecodeString(part)
-			if err != nil || len(decoded) != 1 {
-				return nil, fmt.Errorf("invalid hex pattern: %s", part)
+	pm := &PatternMatcher{}
+	pm.minLength, pm.maxLength = sequenceLength(elements)
+	if pm.minLength == 0 {
+		return nil, &PatternError{Pattern: pattern, Msg: "pattern can match zero bytes"}
+	}
+
+	// Collect the bytes at fixed offsets; the full elements are only needed
+	// once the pattern has a group or a jump of variable length
+	plain := true
+	for _, element := range elements {
+		values, masks, ok := element.fixedBytes()
+		if !ok {
+			plain = false
+			break
+		}
+		if element.kind == elementGroup {
+			plain = false
+		}
+		pm.patternBytes = append(pm.patternBytes, values...)
+		pm.masks = append(pm.masks, masks...)
+	}
+	if !plain {
+		pm.program, pm.memoRows = compilePattern(elements)
+	}
+
+	pm.anchor = newPatternAnchor(pm.patternBytes, pm.masks)
+	return pm, nil
+}
+
+// fixedBytes returns the values and masks the element matches at fixed offsets.
+// For a group of alternatives of the same length, the mask keeps the bits that
+// all alternatives agree on. ok is false if the length of the element varies.
+func (e patternElement) fixedBytes() (values, masks []byte, ok bool) {
+	switch e.kind {
+	case elementByte:
+		return []byte{e.value}, []byte{e.mask}, true
+	case elementJump:
+		if e.min != e.max {
+			return nil, nil, false
+		}
+		return make([]byte, e.min), make([]byte, e.min), true
+	}
+
+	for i, alternative := range e.alternatives {
+		var altValues, altMasks []byte
+		for _, element := range alternative {
+			elementValues, elementMasks, ok := element.fixedBytes()
+			if !ok {
+				return nil, nil, false
+			}
+			altValues = append(altValues, elementValues...)
+			altMasks = append(altMasks, elementMasks...)
+		}
+
+		if i == 0 {
+			values, masks = altValues, altMasks
+			continue
+		}
+		if len(altValues) != len(values) {
+			return nil, nil, false
+		}
+		for j := range values {
+			masks[j] &= altMasks[j] &^ (values[j] ^ altValues[j])
+			values[j] &= masks[j]
+		}
+	}
+	return values, masks, true
+}
+
+// sequenceLength returns the shortest and longest input the elements can match
+func sequenceLength(elements []patternElement) (minLength, maxLength int) {
+	for _, e := range elements {
+		switch e.kind {
+		case elementByte:
+			minLength++
+			maxLength++
+		case elementJump:
+			minLength += e.min
+			maxLength += e.max
+		case elementGroup:
+			groupMin, groupMax := sequenceLength(e.alternatives[0])
+			for _, alternative := range e.alternatives[1:] {
+				altMin, altMax := sequenceLength(alternative)
+				groupMin, groupMax = min(groupMin, altMin), max(groupMax, altMax)
 			}
-			patternBytes[i] = decoded[0]
+			minLength += groupMin
+			maxLength += groupMax
 		}
 	}
+	return minLength, maxLength
+}
 
-	return &PatternMatcher{
-		patternBytes:  patternBytes,
-		wildcardMask:  wildcardMask,
-		patternLength: len(parts),
-	}, nil
+// patternParser parses the tokens of a pattern
+type patternParser struct {
+	pattern string
+	tokens  []patternToken
+	// next is the index of the next token to parse
+	next int
+}
+
+// errorAt returns a PatternError for the token
+func (p *patternParser) errorAt(token patternToken, msg string) error {
+	return &PatternError{Pattern: p.pattern, Pos: token.pos, Token: token.text, Msg: msg}
+}
+
+// parseSequence parses elements up to the end of the pattern or the next | or )
+func (p *patternParser) parseSequence() ([]patternElement, error) {
+	var elements []patternElement
+	for p.next < len(p.tokens) {
+		token := p.tokens[p.next]
+		if token.text == "|" || token.text == ")" {
+			break
+		}
+		p.next++
+
+		switch {
+		case token.text == "(":
+			group, err := p.parseGroup(token)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, group)
+		case strings.HasPrefix(token.text, "["):
+			jump, err := p.parseJump(token)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, jump)
+		default:
+			value, mask, msg := parsePatternByte(token.text)
+			if msg != "" {
+				return nil, p.errorAt(token, msg)
+			}
+			elements = append(elements, patternElement{kind: elementByte, value: value & mask, mask: mask})
+		}
+	}
+	return elements, nil
+}
+
+// parseGroup parses the alternatives of a group after its opening parenthesis
+func (p *patternParser) parseGroup(open patternToken) (patternElement, error) {
+	group := patternElement{kind: elementGroup}
+	for {
+		alternative, err := p.parseSequence()
+		if err != nil {
+			return group, err
+		}
+		if p.next == len(p.tokens) {
+			return group, p.errorAt(open, "group is not closed")
+		}
+
+		end := p.tokens[p.next]
+		p.next++
+		if len(alternative) == 0 {
+			return group, p.errorAt(end, "empty alternative")
+		}
+		group.alternatives = append(group.alternatives, alternative)
+		if end.text == ")" {
+			return group, nil
+		}
+	}
+}
+
+// parseJump parses a jump such as [4] or [2-8]
+func (p *patternParser) parseJump(token patternToken) (patternElement, error) {
+	inner, closed := strings.CutSuffix(token.text[1:], "]")
+	if !closed {
+		return patternElement{}, p.errorAt(token, "jump is not closed with ]")
+	}
+
+	minText, maxText, isRange := strings.Cut(inner, "-")
+	if !isRange {
+		maxText = minText
+	}
+	minJump, minErr := strconv.Atoi(minText)
+	maxJump, maxErr := strconv.Atoi(maxText)
+	switch {
+	case minErr != nil || maxErr != nil || minJump < 0:
+		return patternElement{}, p.errorAt(token, "expected a jump such as [4] or [2-8]")
+	case maxJump < minJump:
+		return patternElement{}, p.errorAt(token, "jump maximum is less than its minimum")
+	case maxJump > maxPatternJump:
+		return patternElement{}, p.errorAt(token, fmt.Sprintf("jump is longer than %d bytes", maxPatternJump))
+	}
+	return patternElement{kind: elementJump, min: minJump, max: maxJump}, nil
+}
+
+// parsePatternByte parses one byte of a pattern into its value and mask, or
+// returns a description of what is wrong with it
+func parsePatternByte(token string) (value, mask byte, msg string) {
+	if valueText, maskText, found := strings.Cut(token, "&"); found {
+		value, ok := parseHexByte(valueText)
+		if !ok {
+			return 0, 0, "expected a hex byte before &"
+		}
+		mask, ok := parseHexByte(maskText)
+		if !ok {
+			return 0, 0, "expected a hex mask byte after &"
+		}
+		return value, mask, ""
+	}
+
+	if len(token) != 2 {
+		return 0, 0, "expected a hex byte, ??, a nibble wildcard such as 4? or a mask such as E8&F0"
+	}
+	for i := range 2 {
+		shift := 4 * (1 - i)
+		if token[i] == '?' {
+			continue
+		}
+		nibble, ok := parseHexDigit(token[i])
+		if !ok {
+			return 0, 0, "expected a hex byte, ??, a nibble wildcard such as 4? or a mask such as E8&F0"
+		}
+		value |= nibble << shift
+		mask |= 0xF << shift
+	}
+	return value, mask, ""
+}
+
+// parseHexByte parses exactly two hex digits
+func parseHexByte(text string) (byte, bool) {
+	if len(text) != 2 {
+		return 0, false
+	}
+	high, ok1 := parseHexDigit(text[0])
+	low, ok2 := parseHexDigit(text[1])
+	return high<<4 | low, ok1 && ok2
+}
+
+// parseHexDigit returns the value of a hex digit
+func parseHexDigit(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// patternToken is a part of a pattern
+type patternToken struct {
+	text string
+	// pos is the byte offset of the token in the pattern
+	pos int
+}
+
+// splitPattern splits a pattern into tokens, remembering where each one starts.
+// Tokens are separated by whitespace; parentheses and | are tokens of their
+// own, and a jump runs from [ to the next ].
+func splitPattern(pattern string) []patternToken {
+	var tokens []patternToken
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			tokens = append(tokens, patternToken{text: pattern[start:end], pos: start})
+			start = -1
+		}
+	}
+
+	for i := 0; i < len(pattern); {
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		switch {
+		case unicode.IsSpace(r):
+			flush(i)
+		case r == '(' || r == ')' || r == '|':
+			flush(i)
+			tokens = append(tokens, patternToken{text: pattern[i : i+1], pos: i})
+		case r == '[':
+			flush(i)
+			end := len(pattern)
+			if j := strings.IndexByte(pattern[i:], ']'); j >= 0 {
+				end = i + j + 1
+			}
+			tokens = append(tokens, patternToken{text: pattern[i:end], pos: i})
+			size = end - i
+		case start < 0:
+			start = i
+		}
+		i += size
+	}
+	flush(len(pattern))
+	return tokens
 }
 
 // FindMatches finds all occurrences of the pattern in the given data
 func (pm *PatternMatcher) FindMatches(data []byte, ignoreCase bool) []int {
-	if pm.patternLength == 0 || pm.patternLength > len(data) {
-		return nil
+	var matches []int
+	pm.find(data, ignoreCase, func(start, end int) {
+		matches = append(matches, start)
+	})
+	return matches
+}
+
+// FindSpans finds all occurrences of the pattern in the given data together
+// with the bytes each one covers, which vary for patterns with jumps or groups
+func (pm *PatternMatcher) FindSpans(data []byte, ignoreCase bool) []MatchSpan {
+	var spans []MatchSpan
+	pm.find(data, ignoreCase, func(start, end int) {
+		spans = append(spans, MatchSpan{Start: start, End: end})
+	})
+	return spans
+}
+
+// find calls yield for every match in data in ascending order of start.
+// Candidates are located by searching for the longest run of exact bytes at a
+// fixed offset, and the full pattern is only compared at those positions.
+func (pm *PatternMatcher) find(data []byte, ignoreCase bool, yield func(start, end int)) {
+	if pm.minLength == 0 || pm.minLength > len(data) {
+		return
 	}
 
-	var matches []int
-	dataLen := len(data)
+	lastStart := len(data) - pm.minLength
+	var state programState
 
-	for i := 0; i <= dataLen-pm.patternLength; i++ {
-		if pm.matchesAt(data, i, ignoreCase) {
-			matches = append(matches, i)
+	if pm.anchor == nil {
+		// Without exact bytes every position is a candidate
+		for i := 0; i <= lastStart; i++ {
+			if end, ok := pm.matchAt(data, i, ignoreCase, &state); ok {
+				yield(i, end)
+			}
 		}
+		return
 	}
 
-	return matches
+	// Only search where the anchor leaves room for the rest of the pattern
+	offset := pm.anchor.offset
+	window := data[offset : lastStart+offset+len(pm.anchor.literal)]
+	pm.anchor.find(window, ignoreCase, func(pos int) {
+		if end, ok := pm.matchAt(data, pos, ignoreCase, &state); ok {
+			yield(pos, end)
+		}
+	})
+}
+
+// matchAt returns the end of the match at pos, or false if the pattern does not
+// match there. The caller ensures data holds at least minLength bytes from pos.
+// state is only used by patterns with a program, and only by one goroutine.
+func (pm *PatternMatcher) matchAt(data []byte, pos int, ignoreCase bool, state *programState) (int, bool) {
+	if pm.program != nil {
+		end := state.match(pm, data, pos, ignoreCase)
+		return end, end >= 0
+	}
+
+	candidate := data[pos : pos+len(pm.patternBytes)]
+	for j, patternByte := range pm.patternBytes {
+		if !matchByte(candidate[j], patternByte, pm.masks[j], ignoreCase) {
+			return 0, false
+		}
+	}
+	return pos + len(pm.patternBytes), true
+}
+
+// matchByte checks if a byte matches the value under the mask.
+// ignoreCase only applies to bytes without wildcard bits.
+func matchByte(dataByte, patternByte, mask byte, ignoreCase bool) bool {
+	if mask != 0xFF {
+		return dataByte&mask == patternByte
+	}
+	if ignoreCase {
+		// ASCII case-insensitive comparison
+		return foldTable[dataByte] == foldTable[patternByte]
+	}
+	return dataByte == patternByte
+}
+
+// patternOp is the operation of a compiled pattern instruction
+type patternOp int
+
+const (
+	// opByte matches one byte by value and mask
+	opByte patternOp = iota
+	// opJump skips between min and max bytes
+	opJump
+	// opSplit continues with each of its alternatives in turn
+	opSplit
+	// opGoto continues at next
+	opGoto
+	// opMatch ends the match
+	opMatch
+)
+
+// patternInst is an instruction of a compiled pattern. Control only moves
+// forward, so matching the program never loops.
+type patternInst struct {
+	op    patternOp
+	value byte
+	mask  byte
+	min   int
+	max   int
+	// alts holds the first instructions of the alternatives of opSplit
+	alts []int
+	// next is the target of opGoto
+	next int
+	// memo is the row of the instruction in the memo of a programState, -1 if
+	// the instruction is only reached one way. Instructions after a jump of
+	// variable length and after a group are reached from many paths.
+	memo int
 }
 
-// matchesAt checks if the pattern matches at the given position
-func (pm *PatternMatcher) matchesAt(data []byte, pos int, ignoreCase bool) bool {
-	for j := 0; j < pm.patternLength; j++ {
-		if pm.wildcardMask[j] {
-			continue // Skip wildcards
+// compilePattern compiles the parsed pattern into a program and returns it with
+// the number of memoized instructions
+func compilePattern(elements []patternElement) ([]patternInst, int) {
+	program := compileSequence(nil, elements)
+	program = append(program, patternInst{op: opMatch})
+
+	joins := make([]bool, len(program))
+	for i, inst := range program {
+		switch {
+		case inst.op == opGoto:
+			joins[inst.next] = true
+		case inst.op == opJump && inst.min != inst.max:
+			joins[i+1] = true
+		}
+	}
+	rows := 0
+	for i := range program {
+		program[i].memo = -1
+		if joins[i] {
+			program[i].memo = rows
+			rows++
+		}
+	}
+	return program, rows
+}
+
+// compileSequence appends the instructions of the elements to program
+func compileSequence(program []patternInst, elements []patternElement) []patternInst {
+	for _, e := range elements {
+		switch e.kind {
+		case elementByte:
+			program = append(program, patternInst{op: opByte, value: e.value, mask: e.mask})
+		case elementJump:
+			program = append(program, patternInst{op: opJump, min: e.min, max: e.max})
+		case elementGroup:
+			// Each alternative ends with a goto to the instruction after the group
+			split := len(program)
+			program = append(program, patternInst{op: opSplit})
+			var gotos []int
+			for _, alternative := range e.alternatives {
+				program[split].alts = append(program[split].alts, len(program))
+				program = compileSequence(program, alternative)
+				gotos = append(gotos, len(program))
+				program = append(program, patternInst{op: opGoto})
+			}
+			for _, g := range gotos {
+				program[g].next = len(program)
+			}
 		}
+	}
+	return program
+}
+
+// programState matches the program of a pattern at the candidate positions of
+// one search. Alternatives are tried in order and jumps from their shortest
+// length; the first way the whole pattern matches wins. Where several paths
+// meet, the outcome of going on from each position is remembered. It does not
+// depend on where the match started, so it is shared by all candidates whose
+// matches can reach the position, and the memo holds one row per memoized
+// instruction of width positions: a search costs at most the length of the
+// program times the length of the data, however many ways there are to reach
+// the same point. A programState is used for one data slice only.
+type programState struct {
+	program    []patternInst
+	data       []byte
+	ignoreCase bool
+	width      int
+	// ends holds the end of the match going on from a memoized instruction at
+	// a position, -1 if there is none
+	ends []memoEntry
+	// skips holds for a memoized instruction and a position the next position
+	// that is not known to fail, for the jumps before the instruction
+	skips []memoEntry
+}
+
+// memoEntry is an entry of the memo of a programState. Positions share an
+// entry width apart, so the entry holds the position it was set for plus one.
+type memoEntry struct {
+	tag   int
+	value int
+}
+
+// match returns the end of the match of the pattern at pos, or -1
+func (s *programState) match(pm *PatternMatcher, data []byte, pos int, ignoreCase bool) int {
+	if s.program == nil {
+		// A match never covers more than width positions, so those it
+		// reaches do not share memo entries
+		s.program, s.data, s.ignoreCase = pm.program, data, ignoreCase
+		s.width = pm.maxLength + 1
+		s.ends = make([]memoEntry, pm.memoRows*s.width)
+		s.skips = make([]memoEntry, pm.memoRows*s.width)
+	}
+	return s.end(0, pos)
+}
 
-		dataByte := data[pos+j]
-		patternByte := pm.patternBytes[j]
+// end returns where the match going on from instruction pc at pos ends, or -1
+func (s *programState) end(pc, pos int) int {
+	row := s.program[pc].memo
+	if row < 0 {
+		return s.run(pc, pos)
+	}
+	entry := &s.ends[row*s.width+pos%s.width]
+	if entry.tag != pos+1 {
+		*entry = memoEntry{tag: pos + 1, value: s.run(pc, pos)}
+	}
+	return entry.value
+}
 
-		if ignoreCase {
-			// Convert both to uppercase for ASCII case-insensitive comparison
-			if 'a' <= patternByte && patternByte <= 'z' {
-				patternByte -= ('a' - 'A')
+// run executes the program from instruction pc at pos up to its end or the
+// next memoized instruction
+func (s *programState) run(pc, pos int) int {
+	for {
+		inst := &s.program[pc]
+		switch inst.op {
+		case opByte:
+			if pos >= len(s.data) || !matchByte(s.data[pos], inst.value, inst.mask, s.ignoreCase) {
+				return -1
+			}
+			pos++
+		case opJump:
+			if inst.min != inst.max {
+				return s.first(pc+1, pos+inst.min, pos+inst.max)
+			}
+			pos += inst.min
+			if pos > len(s.data) {
+				return -1
 			}
-			if 'a' <= dataByte && dataByte <= 'z' {
-				dataByte -= ('a' - 'A')
+		case opSplit:
+			for _, alt := range inst.alts {
+				if end := s.end(alt, pos); end >= 0 {
+					return end
+				}
 			}
+			return -1
+		case opGoto:
+			return s.end(inst.next, pos)
+		case opMatch:
+			return pos
 		}
 
-		if dataByte != patternByte {
-			return false
+		pc++
+		if s.program[pc].memo >= 0 {
+			return s.end(pc, pos)
+		}
+	}
+}
+
+// first returns the end of the match going on from the memoized instruction pc
+// at the first position in [lo, hi] where there is one, or -1. The positions
+// found to fail are skipped by the jumps that reach pc later.
+func (s *programState) first(pc, lo, hi int) int {
+	hi = min(hi, len(s.data))
+	row := s.program[pc].memo * s.width
+	pos, end := lo, -1
+	for pos <= hi {
+		if next := s.skip(row, pos); next > pos {
+			pos = next
+			continue
 		}
+		if end = s.end(pc, pos); end >= 0 {
+			break
+		}
+		pos++
 	}
 
-	return true
+	// Point every position passed on the way at the one the search stopped at
+	for p := lo; p < pos && p <= hi; {
+		next := max(s.skip(row, p), p+1)
+		s.skips[row+p%s.width] = memoEntry{tag: p + 1, value: pos}
+		p = next
+	}
+	return end
+}
+
+// skip returns the first position from pos in the memo row that is not known
+// to fail
+func (s *programState) skip(row, pos int) int {
+	if entry := s.skips[row+pos%s.width]; entry.tag == pos+1 {
+		return entry.value
+	}
+	return pos
 }
 
-// GetPatternLength returns the length of the pattern in bytes
+// GetPatternLength returns the length of the longest match of the pattern in bytes
 func (pm *PatternMatcher) GetPatternLength() int {
-	return pm.patternLength
+	return pm.maxLength
+}
+
+// GetMinPatternLength returns the length of the shortest match of the pattern in bytes
+func (pm *PatternMatcher) GetMinPatternLength() int {
+	return pm.minLength
+}
+
+// GetMaxPatternLength returns the length of the longest match of the pattern in
+// bytes, the same as GetPatternLength
+func (pm *PatternMatcher) GetMaxPatternLength() int {
+	return pm.maxLength
 }
\ No newline at end of file
@@ -0,0 +1,72 @@
+//go:build linux
+
+package memoryscanner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FindProcessesByName finds all processes with the specified name.
+// The name is compared against the process command name, the executable
+// path and argv[0], so both "WeChatAppEx.exe" under Wine and native names match.
+func FindProcessesByName(name string) ([]uint32, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate processes: %w", err)
+	}
+
+	var pids []uint32
+	for _, entry := range entries {
+		pid, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue // Not a process directory
+		}
+
+		if processNameMatches(uint32(pid), name) {
+			pids = append(pids, uint32(pid))
+		}
+	}
+
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrProcessNotFound, name)
+	}
+
+	return pids, nil
+}
+
+// processNameMatches checks whether any of the names a process is known by equals name
+func processNameMatches(pid uint32, name string) bool {
+	procDir := filepath.Join("/proc", strconv.FormatUint(uint64(pid), 10))
+
+	// comm is truncated to 15 bytes by the kernel, so it only decides short names
+	if comm, err := os.ReadFile(filepath.Join(procDir, "comm")); err == nil {
+		if strings.EqualFold(strings.TrimSpace(string(comm)), name) {
+			return true
+		}
+	}
+
+	if exe, err := os.Readlink(filepath.Join(procDir, "exe")); err == nil {
+		if strings.EqualFold(filepath.Base(exe), name) {
+			return true
+		}
+	}
+
+	if cmdline, err := os.ReadFile(filepath.Join(procDir, "cmdline")); err == nil {
+		argv0, _, _ := bytes.Cut(cmdline, []byte{0})
+		// Wine processes report Windows paths in argv[0]
+		base := string(argv0)
+		if i := strings.LastIndexAny(base, `/\`); i >= 0 {
+			base = base[i+1:]
+		}
+		if base != "" && strings.EqualFold(base, name) {
+			return true
+		}
+	}
+
+	return false
+}
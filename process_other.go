@@ -0,0 +1,24 @@
+//go:build !linux && !windows
+
+package memoryscanner
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// errUnsupportedPlatform is returned for live processes on platforms without a
+// process memory reader; dump files and snapshots can still be scanned
+var errUnsupportedPlatform = fmt.Errorf("live processes are not supported on %s: %w", runtime.GOOS, errors.ErrUnsupported)
+
+// FindProcessesByName finds all processes with the specified name.
+// It is not supported on this platform.
+func FindProcessesByName(name string) ([]uint32, error) {
+	return nil, errUnsupportedPlatform
+}
+
+// openProcessSource reports that live processes cannot be read on this platform
+func openProcessSource(pid uint32) (MemorySource, error) {
+	return nil, errUnsupportedPlatform
+}
@@ -1,3 +1,5 @@
+//go:build windows
+
 package memoryscanner
 
 import (
@@ -40,8 +42,8 @@ func FindProcessesByName(name string) ([]uint32, error) {
 	}
 
 	if len(pids) == 0 {
-		return nil, fmt.Errorf("process not found: %s", name)
+		return nil, fmt.Errorf("%w: %s", ErrProcessNotFound, name)
 	}
 
 	return pids, nil
-}
\ No newline at end of file
+}
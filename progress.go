@@ -0,0 +1,106 @@
+package memoryscanner
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultProgressInterval is the minimum time between progress reports when
+// ScanOptions.ProgressInterval is not set
+const DefaultProgressInterval = 200 * time.Millisecond
+
+// ScanProgress describes how far a scan has got
+type ScanProgress struct {
+	// BytesScanned is the number of bytes of the selected regions processed so
+	// far, including bytes that turned out to be unreadable
+	BytesScanned uint64
+	// TotalBytes is the size of all selected regions, estimated from the region table
+	TotalBytes uint64
+	// Region is the region most recently started
+	Region Region
+	// Matches is the number of matches delivered to the handler so far
+	Matches int
+	// Elapsed is the time since the scan started
+	Elapsed time.Duration
+}
+
+// Percent returns the share of TotalBytes scanned so far, from 0 to 100
+func (p ScanProgress) Percent() float64 {
+	if p.TotalBytes == 0 {
+		return 100
+	}
+	return float64(p.BytesScanned) * 100 / float64(p.TotalBytes)
+}
+
+// Remaining estimates the time left from the rate of the scan so far.
+// It returns 0 until some bytes have been scanned.
+func (p ScanProgress) Remaining() time.Duration {
+	if p.BytesScanned == 0 || p.BytesScanned >= p.TotalBytes {
+		return 0
+	}
+	rate := float64(p.BytesScanned) / p.Elapsed.Seconds()
+	return time.Duration(float64(p.TotalBytes-p.BytesScanned) / rate * float64(time.Second))
+}
+
+// ProgressHandler is called periodically during a scan, always from the
+// goroutine running Scan
+type ProgressHandler func(progress ScanProgress)
+
+// progressReporter tracks the progress of a scan. Region scanners update the
+// counters from any goroutine; reports are made from the goroutine running Scan.
+type progressReporter struct {
+	handler  ProgressHandler
+	interval time.Duration
+	start    time.Time
+	total    uint64
+
+	scanned atomic.Uint64
+	current atomic.Pointer[Region]
+
+	lastReport time.Time
+}
+
+// newProgressReporter returns a reporter for the ranges of a scan, or nil if
+// the scan has no progress handler
+func newProgressReporter(opts ScanOptions, ranges []scanRange, start time.Time) *progressReporter {
+	if opts.Progress == nil {
+		return nil
+	}
+
+	interval := opts.ProgressInterval
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+
+	p := &progressReporter{
+		handler:    opts.Progress,
+		interval:   interval,
+		start:      start,
+		lastReport: start,
+	}
+	for _, r := range ranges {
+		p.total += r.Size()
+	}
+	return p
+}
+
+// report calls the handler if the interval has passed since the last report,
+// or always when force is set
+func (p *progressReporter) report(matches int, force bool) {
+	now := time.Now()
+	if !force && now.Sub(p.lastReport) < p.interval {
+		return
+	}
+	p.lastReport = now
+
+	progress := ScanProgress{
+		BytesScanned: p.scanned.Load(),
+		TotalBytes:   p.total,
+		Matches:      matches,
+		Elapsed:      now.Sub(p.start),
+	}
+	if region := p.current.Load(); region != nil {
+		progress.Region = *region
+	}
+	p.handler(progress)
+}
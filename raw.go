@@ -0,0 +1,141 @@
+package memoryscanner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RawFile maps the contents of a raw memory file to the address it was captured from
+type RawFile struct {
+	// Path is the file to read
+	Path string
+	// BaseAddress is the address of the first byte of the file in the original address space
+	BaseAddress Address
+}
+
+// RawSource is a MemorySource over raw memory files such as a dd of a region,
+// a hibernation file excerpt or a firmware image. Each file is one readable region.
+type RawSource struct {
+	*segmentSource
+}
+
+// OpenRawFiles opens raw memory files mapped at the given base addresses.
+// The files must not overlap in the original address space.
+func OpenRawFiles(files ...RawFile) (*RawSource, error) {
+	if len(files) == 0 {
+		return nil, errors.New("no raw files")
+	}
+
+	var closer multiCloser
+	segments := make([]segment, 0, len(files))
+	for _, rawFile := range files {
+		file, err := os.Open(rawFile.Path)
+		if err != nil {
+			closer.Close()
+			return nil, err
+		}
+		closer = append(closer, file)
+
+		info, err := file.Stat()
+		if err != nil {
+			closer.Close()
+			return nil, err
+		}
+		if info.IsDir() {
+			closer.Close()
+			return nil, fmt.Errorf("%s is a directory", rawFile.Path)
+		}
+		if info.Size() == 0 {
+			continue
+		}
+
+		segments = append(segments, segment{
+			region: Region{
+				BaseAddress: rawFile.BaseAddress,
+				Size:        uint64(info.Size()),
+				Protection:  ProtectRead | ProtectWrite,
+				State:       StateCommit,
+				Name:        rawFile.Path,
+			},
+			reader:   file,
+			dataSize: uint64(info.Size()),
+		})
+	}
+
+	source := newSegmentSource(segments, closer)
+	for i := 1; i < len(source.segments); i++ {
+		prev, next := source.segments[i-1].region, source.segments[i].region
+		if next.BaseAddress < prev.End() {
+			source.Close()
+			return nil, fmt.Errorf("%s overlaps %s at %s", next.Name, prev.Name, next.BaseAddress)
+		}
+	}
+
+	return &RawSource{segmentSource: source}, nil
+}
+
+// OpenRawDir opens every file in dir whose name starts with its base address in
+// hexadecimal, e.g. "0x7FF6A000.bin" or "7ff6a000-7ff6b000.dmp". Other files are ignored.
+func OpenRawDir(dir string) (*RawSource, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []RawFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		baseAddress, ok := parseRawFileAddress(entry.Name())
+		if !ok {
+			continue
+		}
+		files = append(files, RawFile{
+			Path:        filepath.Join(dir, entry.Name()),
+			BaseAddress: baseAddress,
+		})
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files named by base address in %s", dir)
+	}
+
+	return OpenRawFiles(files...)
+}
+
+// parseRawFileAddress parses the leading hexadecimal address of a file name.
+// The address must be followed by the end of the name, '-', '_' or '.'.
+func parseRawFileAddress(name string) (Address, bool) {
+	end := strings.IndexAny(name, "-_.")
+	if end < 0 {
+		end = len(name)
+	}
+	if end == 0 {
+		return 0, false
+	}
+
+	address, err := ParseAddress(name[:end])
+	if err != nil {
+		return 0, false
+	}
+	return address, true
+}
+
+// multiCloser closes several files
+type multiCloser []*os.File
+
+// Close closes all files and returns the first error
+func (m multiCloser) Close() error {
+	var firstErr error
+	for _, file := range m {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,135 @@
+package memoryscanner
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// regexpMatcher is the scanMatcher of ScanOptions.Regexp
+type regexpMatcher struct {
+	re *regexp.Regexp
+	// at matches the byte before a position followed by re, anchored to the
+	// start of the text, so that re is tried at the position with the byte
+	// before it in view for ^, \b and \B. find only uses it at character
+	// boundaries, where that byte is a character of its own for the leading .
+	at      *regexp.Regexp
+	longest int
+}
+
+// regexpLookaround returns the number of bytes a regular expression scan reads
+// beyond the context on each side of a chunk, so that assertions such as \b and
+// $ next to a chunk boundary see the neighbouring byte, and a UTF-8 character
+// crossing the boundary is seen whole
+func regexpLookaround(opts ScanOptions) int {
+	if opts.Regexp == "" {
+		return 0
+	}
+	return utf8.UTFMax - 1
+}
+
+// newRegexpMatcher compiles the regular expression of the scan options
+func newRegexpMatcher(opts ScanOptions) (*regexpMatcher, error) {
+	flags := "(?s)"
+	if opts.IgnoreCase {
+		flags = "(?is)"
+	}
+
+	re, err := regexp.Compile(flags + opts.Regexp)
+	if err != nil {
+		patternErr := &PatternError{Pattern: opts.Regexp, Msg: err.Error()}
+		var syntaxErr *syntax.Error
+		if errors.As(err, &syntaxErr) {
+			// Errors about the whole expression quote it with the flags in front
+			expr := strings.TrimPrefix(syntaxErr.Expr, flags)
+			patternErr.Msg = string(syntaxErr.Code)
+			if pos := strings.Index(opts.Regexp, expr); pos >= 0 && expr != "" {
+				patternErr.Pos, patternErr.Token = pos, expr
+			}
+		}
+		return nil, patternErr
+	}
+
+	longest := opts.MaxMatchLength
+	if longest <= 0 {
+		longest = DefaultMaxMatchLength
+	}
+	at := regexp.MustCompile(flags + `\A.(?:` + opts.Regexp + `)`)
+	return &regexpMatcher{re: re, at: at, longest: longest}, nil
+}
+
+// maxLength returns the longest match that is guaranteed to be found whole
+func (m *regexpMatcher) maxLength() int {
+	return m.longest
+}
+
+// find returns the non-empty matches of the regular expression in data[from:].
+// re only sees the text it is given, so a match at from is looked for with at,
+// which sees the byte before from; matches found by re further on already have
+// their neighbouring bytes in view. A search never starts inside a UTF-8
+// character that began before it, as the regexp steps over such characters whole.
+func (m *regexpMatcher) find(data []byte, from int) []patternMatch {
+	var matches []patternMatch
+	for pos := from; pos <= len(data); {
+		var loc []int
+		if pos > 0 {
+			if end := runeEnd(data, pos); end > pos {
+				pos = end
+				continue
+			}
+			if loc = m.at.FindSubmatchIndex(data[pos-1:]); loc != nil {
+				for i := range loc {
+					if loc[i] >= 0 {
+						loc[i] += pos - 1
+					}
+				}
+				loc[0] = pos
+			}
+		}
+		if loc == nil {
+			loc = m.re.FindSubmatchIndex(data[pos:])
+			if loc == nil {
+				break
+			}
+			for i := range loc {
+				if loc[i] >= 0 {
+					loc[i] += pos
+				}
+			}
+			if pos > 0 && loc[0] == pos {
+				// re took pos for the start of the text, but at found no match there
+				pos++
+				continue
+			}
+		}
+
+		if loc[1] == loc[0] {
+			pos = loc[0] + 1
+			continue
+		}
+		match := patternMatch{offset: loc[0], length: loc[1] - loc[0]}
+		if len(loc) > 2 {
+			match.captures = loc[2:]
+		}
+		matches = append(matches, match)
+		pos = loc[1]
+	}
+	return matches
+}
+
+// runeEnd returns the end of the UTF-8 character that starts before pos in data
+// and ends after it, or pos if pos is at a character boundary. Bytes that are
+// not valid UTF-8 count as characters of one byte, as they do for the regexp.
+func runeEnd(data []byte, pos int) int {
+	for start := max(pos-utf8.UTFMax+1, 0); start < pos; start++ {
+		if data[start] < utf8.RuneSelf {
+			continue
+		}
+		if _, size := utf8.DecodeRune(data[start:]); start+size > pos {
+			return start + size
+		}
+	}
+	return pos
+}
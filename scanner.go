@@ -2,61 +2,154 @@ package memoryscanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"unsafe"
-
-	"golang.org/x/sys/windows"
+	"iter"
+	"time"
 )
 
-// Scanner represents a memory scanner for a specific process
+// errStopScan is returned internally when the match handler asks to stop scanning
+var errStopScan = errors.New("scan stopped by handler")
+
+// Scanner represents a memory scanner for a specific process or memory source
 type Scanner struct {
-	pid        uint32
-	processHandle windows.Handle
+	pid    uint32
+	source MemorySource
 }
 
 // NewScanner creates a new memory scanner for the specified process ID
 func NewScanner(pid uint32) (*Scanner, error) {
-	hProcess, err := windows.OpenProcess(
-		windows.PROCESS_VM_READ|windows.PROCESS_QUERY_INFORMATION,
-		false,
-		pid,
-	)
+	source, err := openProcessSource(pid)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open process: %w", err)
 	}
 
 	return &Scanner{
-		pid:        pid,
-		processHandle: hProcess,
+		pid:    pid,
+		source: source,
 	}, nil
 }
 
-// Close closes the process handle
+// NewScannerFromSource creates a new memory scanner that searches the given source.
+// The scanner takes ownership of the source and closes it in Close.
+func NewScannerFromSource(source MemorySource) *Scanner {
+	return &Scanner{source: source}
+}
+
+// Close closes the underlying memory source
 func (s *Scanner) Close() error {
-	if s.processHandle != 0 {
-		windows.CloseHandle(s.processHandle)
-		s.processHandle = 0
+	if s.source != nil {
+		err := s.source.Close()
+		s.source = nil
+		return err
 	}
 	return nil
 }
 
-// GetPID returns the process ID that this scanner is attached to
+// GetPID returns the process ID that this scanner is attached to,
+// or 0 if the scanner was created from a MemorySource
 func (s *Scanner) GetPID() uint32 {
 	return s.pid
 }
 
-// Scan scans the process memory for the specified pattern
+// Source returns the memory source that this scanner reads from
+func (s *Scanner) Source() MemorySource {
+	return s.source
+}
+
+// Regions returns the memory map of the source in ascending address order,
+// including regions that are not readable
+func (s *Scanner) Regions(ctx context.Context) ([]Region, error) {
+	if s.source == nil {
+		return nil, errors.New("scanner is closed")
+	}
+
+	regions, err := s.source.Regions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate regions: %w", err)
+	}
+	return regions, nil
+}
+
+// Scan scans the memory source for the specified pattern
 func (s *Scanner) Scan(ctx context.Context, opts ScanOptions) error {
-	patternMatcher, err := NewPatternMatcher(opts.Pattern)
+	startTime := time.Now()
+	var stats ScanStats
+	if opts.Stats != nil {
+		defer func() {
+			stats.Elapsed = time.Since(startTime)
+			*opts.Stats = stats
+		}()
+	}
+
+	patternMatcher, err := newScanMatcher(opts)
 	if err != nil {
-		return fmt.Errorf("invalid pattern: %w", err)
+		return err
+	}
+
+	if err := opts.Filter.validate(); err != nil {
+		return err
+	}
+
+	regions := opts.Regions
+	if regions == nil {
+		regions, err = s.Regions(ctx)
+		if err != nil {
+			return err
+		}
+	} else if s.source == nil {
+		return errors.New("scanner is closed")
+	}
+
+	// Collect the parts of the selected regions inside the requested address range
+	var ranges []scanRange
+	for _, region := range regions {
+		// Clip the region to the requested address range
+		start := max(region.BaseAddress, opts.MinAddress)
+		end := min(region.End(), opts.MaxAddress)
+		if end <= start {
+			continue
+		}
+
+		// Check if this memory region is readable and selected
+		switch {
+		case region.State != StateCommit:
+			stats.RegionsSkippedState++
+		case !region.Readable():
+			stats.RegionsSkippedProtection++
+		case !opts.Filter.Match(region):
+			stats.RegionsSkippedFilter++
+		default:
+			ranges = append(ranges, scanRange{AddressRange: AddressRange{Start: start, End: end}, region: region})
+		}
+	}
+
+	progress := newProgressReporter(opts, ranges, startTime)
+
+	if opts.Workers > 1 && len(ranges) > 1 {
+		return s.scanParallel(ctx, ranges, patternMatcher, opts, &stats, progress)
 	}
 
-	var mbi windows.MemoryBasicInformation
-	address := uint64(opts.MinAddress)
-	maxAddress := uint64(opts.MaxAddress)
+	scanner := &regionScanner{
+		source:   s.source,
+		matcher:  patternMatcher,
+		opts:     opts,
+		budget:   newScanBudget(opts, 1, readMargin(opts, patternMatcher)),
+		progress: progress,
+	}
+	if progress != nil {
+		scanner.afterChunk = func() bool {
+			progress.report(scanner.stats.MatchesFound, false)
+			return true
+		}
+	}
+	defer func() {
+		// Every match found on this goroutine went straight to the handler
+		scanner.stats.MatchesDelivered = scanner.stats.MatchesFound
+		stats.add(scanner.stats)
+	}()
 
-	for address < maxAddress {
+	for _, r := range ranges {
 		// Check if context was cancelled
 		select {
 		case <-ctx.Done():
@@ -64,71 +157,201 @@ func (s *Scanner) Scan(ctx context.Context, opts ScanOptions) error {
 		default:
 		}
 
-		err = windows.VirtualQueryEx(s.processHandle, uintptr(address), &mbi, unsafe.Sizeof(mbi))
+		err := scanner.scanRegion(ctx, r)
+		if errors.Is(err, errStopScan) {
+			return nil
+		}
 		if err != nil {
-			break
+			return err
 		}
+	}
 
-		baseAddr := uint64(mbi.BaseAddress)
-		regionSize := uint64(mbi.RegionSize)
+	if progress != nil {
+		progress.report(scanner.stats.MatchesFound, true)
+	}
+	return nil
+}
 
-		// Check if this memory region is readable
-		if s.isReadableRegion(&mbi) {
-			if err := s.scanRegion(ctx, baseAddr, regionSize, maxAddress, patternMatcher, opts); err != nil {
-				return err
+// Matches returns an iterator over the matches of a scan with the given options.
+// Breaking out of the loop stops the scan. If the scan fails, the error is
+// yielded once with a zero Match as the last element. opts.Handler is ignored;
+// with ReuseMatchData, Data is only valid until the next iteration.
+func (s *Scanner) Matches(ctx context.Context, opts ScanOptions) iter.Seq2[Match, error] {
+	return func(yield func(Match, error) bool) {
+		stopped := false
+		opts.Handler = func(match Match) bool {
+			if !yield(match, nil) {
+				stopped = true
+				return false
 			}
+			return true
 		}
 
-		// Move to next region
-		address = baseAddr + regionSize
-		if regionSize == 0 {
-			address++
+		if err := s.Scan(ctx, opts); err != nil && !stopped {
+			yield(Match{}, err)
 		}
 	}
-
-	return nil
 }
 
-// isReadableRegion checks if a memory region is readable
-func (s *Scanner) isReadableRegion(mbi *windows.MemoryBasicInformation) bool {
-	isReadable := mbi.Protect&(windows.PAGE_READONLY|windows.PAGE_READWRITE|
-		windows.PAGE_EXECUTE_READ|windows.PAGE_EXECUTE_READWRITE) != 0
-	isCommitted := mbi.State == windows.MEM_COMMIT
+// readMargin returns the bytes read beside each chunk: the overlap needed for
+// matches crossing the chunk end plus the requested context
+func readMargin(opts ScanOptions, matcher scanMatcher) int {
+	return maxMatchLength(opts, matcher.maxLength()) - 1 + max(opts.ContextBefore, 0) + max(opts.ContextAfter, 0) +
+		2*regexpLookaround(opts)
+}
 
-	return isReadable && isCommitted
+// scanRange is the part of a selected region inside the requested address range
+type scanRange struct {
+	AddressRange
+	region Region
 }
 
-// scanRegion scans a specific memory region for matches
-func (s *Scanner) scanRegion(ctx context.Context, baseAddr, regionSize, maxAddress uint64,
-	matcher *PatternMatcher, opts ScanOptions) error {
+// regionScanner scans regions on one goroutine of a scan, reusing its read
+// buffers and match data slabs from region to region
+type regionScanner struct {
+	source  MemorySource
+	matcher scanMatcher
+	opts    ScanOptions
+	budget  *scanBudget
+	arena   matchArena
+	stats   ScanStats
+	// progress, if set, is told about every chunk scanned
+	progress *progressReporter
+	// afterChunk, if set, is called after each chunk once its read buffer has
+	// been released. Returning false stops the scan.
+	afterChunk func() bool
+	// resume is the end of the last match of a regular expression in the current region
+	resume Address
+}
 
-	// Calculate read bounds
-	readEnd := baseAddr + regionSize
-	if readEnd > maxAddress {
-		readEnd = maxAddress
+// scanRegion scans a specific memory region for matches. The region is read in
+// chunks of the budget's chunk size; consecutive chunks overlap by patternLength-1
+// bytes so that a match crossing a chunk boundary is found exactly once, by the
+// chunk in which it starts. Each read also takes in the context bytes around the
+// chunk. Pages that cannot be read are reported to opts.UnreadableHandler and
+// the readable pages around them are still scanned.
+func (rs *regionScanner) scanRegion(ctx context.Context, r scanRange) error {
+	if rs.progress != nil {
+		rs.progress.current.Store(&r.region)
 	}
+	rs.resume = 0
 
-	if readEnd <= baseAddr {
-		return nil
+	baseAddr := uint64(r.Start)
+	regionSize := r.Size()
+	chunkSize := rs.budget.chunkSize
+	lookaround := uint64(regexpLookaround(rs.opts))
+	before := uint64(max(rs.opts.ContextBefore, 0)) + lookaround
+	after := uint64(maxMatchLength(rs.opts, rs.matcher.maxLength())-1) + uint64(max(rs.opts.ContextAfter, 0)) + lookaround
+
+	holes := holeRecorder{handler: rs.opts.UnreadableHandler}
+	var readable uint64
+	finished := false
+	defer func() {
+		if readable > 0 {
+			rs.stats.RegionsVisited++
+		} else if finished {
+			rs.stats.RegionsSkippedReadError++
+		}
+	}()
+
+	for chunkOffset := uint64(0); chunkOffset < regionSize; chunkOffset += chunkSize {
+		// Check if context was cancelled
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		// The chunk owns [ownStart, ownEnd) of the read; the bytes before and
+		// after it are context and overlap that belong to the neighbouring chunks
+		readOffset := chunkOffset - min(chunkOffset, before)
+		readAddr := baseAddr + readOffset
+		readLength := min(regionSize, chunkOffset+chunkSize+after) - readOffset
+		ownStart := int(chunkOffset - readOffset)
+		ownEnd := ownStart + int(min(regionSize-chunkOffset, chunkSize))
+
+		buffer, err := rs.budget.acquire(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Read memory chunk, retrying page by page if part of it is unreadable
+		runs, err := readWithFallback(rs.source, buffer[:readLength], Address(readAddr))
+		if err != nil {
+			rs.budget.release(buffer)
+			return err
+		}
+
+		// Record the unreadable parts of this chunk
+		position := ownStart
+		chunkReadable := 0
+		for _, run := range runs {
+			holes.add(Address(readAddr), position, min(run.start, ownEnd))
+			position = max(position, run.end)
+			chunkReadable += max(min(run.end, ownEnd)-max(run.start, ownStart), 0)
+		}
+		holes.add(Address(readAddr), position, ownEnd)
+		readable += uint64(chunkReadable)
+		rs.stats.BytesScanned += uint64(chunkReadable)
+		rs.stats.BytesUnreadable += uint64(ownEnd - ownStart - chunkReadable)
+
+		for _, run := range runs {
+			if run.start >= ownEnd {
+				break
+			}
+			if run.end <= ownStart {
+				continue
+			}
+			err = rs.scanRun(ctx, buffer[run.start:run.end], readAddr+uint64(run.start),
+				ownStart-run.start, ownEnd-run.start, &r.region)
+			if err != nil {
+				break
+			}
+		}
+
+		rs.budget.release(buffer)
+		if err != nil {
+			return err
+		}
+		if rs.progress != nil {
+			rs.progress.scanned.Add(uint64(ownEnd - ownStart))
+		}
+		if rs.afterChunk != nil && !rs.afterChunk() {
+			return errStopScan
+		}
 	}
 
-	readLength := readEnd - baseAddr
-	buffer := make([]byte, readLength)
-	var bytesRead uintptr
+	holes.flush()
+	finished = true
+	return nil
+}
+
+// scanRun reports the matches in a readable run of a chunk that start in [from, limit).
+// Extended matches and context bytes are taken from the run, so they stop at
+// unreadable pages.
+func (rs *regionScanner) scanRun(ctx context.Context, data []byte, runAddr uint64, from, limit int,
+	region *Region) error {
 
-	// Read memory region
-	err := windows.ReadProcessMemory(s.processHandle, uintptr(baseAddr), &buffer[0],
-		uintptr(readLength), &bytesRead)
-	if err != nil || bytesRead == 0 {
+	// Matches starting in the context before the chunk belong to the previous
+	// chunk. Matches of a regular expression do not overlap, so its search
+	// resumes where the last match in the region ended, even in an earlier chunk.
+	start := max(from, 0)
+	if rs.opts.Regexp != "" && rs.resume > Address(runAddr) {
+		start = max(start, int(rs.resume-Address(runAddr)))
+	}
+	if start >= limit {
 		return nil
 	}
 
-	// Trim buffer to actual bytes read
-	buffer = buffer[:bytesRead]
+	// Find matches in this run
+	matches := rs.matcher.find(data, start)
+	for _, found := range matches {
+		offset := found.offset
+		// Matches starting in the overlap belong to the next chunk
+		if offset >= limit {
+			break
+		}
 
-	// Find matches in this region
-	matches := matcher.FindMatches(buffer, opts.IgnoreCase)
-	for _, offset := range matches {
 		// Check if context was cancelled
 		select {
 		case <-ctx.Done():
@@ -136,26 +359,89 @@ func (s *Scanner) scanRegion(ctx context.Context, baseAddr, regionSize, maxAddre
 		default:
 		}
 
-		absoluteAddress := Address(baseAddr + uint64(offset))
-
-		// Extract matched data
-		if offset+matcher.GetPatternLength() > len(buffer) {
-			continue
+		end := offset + found.length
+		if rs.opts.Regexp != "" {
+			rs.resume = Address(runAddr + uint64(end))
+		} else if maxLength := maxMatchLength(rs.opts, found.length); maxLength > found.length {
+			end = rs.opts.Terminator.extend(data, offset, end, min(offset+maxLength, len(data)))
 		}
 
-		matchedData := make([]byte, matcher.GetPatternLength())
-		copy(matchedData, buffer[offset:offset+matcher.GetPatternLength()])
-
 		match := Match{
-			Address: absoluteAddress,
-			Data:    matchedData,
+			Address:  Address(runAddr + uint64(offset)),
+			Data:     data[offset:end:end],
+			Patterns: found.patterns,
+			Region:   *region,
+		}
+		if rs.opts.ContextBefore > 0 {
+			match.Before = data[max(offset-rs.opts.ContextBefore, 0):offset:offset]
+		}
+		if rs.opts.ContextAfter > 0 {
+			afterEnd := min(end+rs.opts.ContextAfter, len(data))
+			match.After = data[end:afterEnd:afterEnd]
+		}
+		if found.captures != nil {
+			match.Captures = make([][]byte, len(found.captures)/2)
+			for i := range match.Captures {
+				if captureStart := found.captures[2*i]; captureStart >= 0 {
+					captureEnd := found.captures[2*i+1]
+					match.Captures[i] = data[captureStart:captureEnd:captureEnd]
+				}
+			}
+		}
+
+		// Without ReuseMatchData the handler owns the bytes, so they must outlive the read buffer
+		if !rs.opts.ReuseMatchData {
+			match.Data = rs.arena.copy(match.Data)
+			if match.Before != nil {
+				match.Before = rs.arena.copy(match.Before)
+			}
+			if match.After != nil {
+				match.After = rs.arena.copy(match.After)
+			}
+			for i, capture := range match.Captures {
+				if capture != nil {
+					match.Captures[i] = rs.arena.copy(capture)
+				}
+			}
 		}
 
 		// Call handler and stop if requested
-		if !opts.Handler(match) {
-			return nil
+		rs.stats.MatchesFound++
+		if !rs.opts.Handler(match) {
+			return errStopScan
 		}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// holeRecorder joins adjacent unreadable ranges of a region before passing them
+// to the UnreadableHandler, so chunking does not split them
+type holeRecorder struct {
+	handler UnreadableHandler
+	pending AddressRange
+}
+
+// add records the unreadable bytes [start, end) of the chunk at chunkAddr
+func (h *holeRecorder) add(chunkAddr Address, start, end int) {
+	if end <= start {
+		return
+	}
+
+	hole := AddressRange{Start: chunkAddr + Address(start), End: chunkAddr + Address(end)}
+	if h.pending.Size() > 0 && h.pending.End == hole.Start {
+		h.pending.End = hole.End
+		return
+	}
+
+	h.flush()
+	h.pending = hole
+}
+
+// flush passes the pending range to the handler
+func (h *holeRecorder) flush() {
+	if h.pending.Size() > 0 && h.handler != nil {
+		h.handler(h.pending)
+	}
+	h.pending = AddressRange{}
+}
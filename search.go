@@ -0,0 +1,172 @@
+package memoryscanner
+
+import "bytes"
+
+// foldTable maps ASCII lowercase letters to uppercase and every other byte to itself
+var foldTable = func() (table [256]byte) {
+	for i := range table {
+		table[i] = byte(i)
+		if 'a' <= i && i <= 'z' {
+			table[i] -= 'a' - 'A'
+		}
+	}
+	return table
+}()
+
+// byteRank estimates how common each byte is in process memory, higher for
+// more common bytes: zeros and 0xFF fill, then text in order of letter
+// frequency. Bytes that are not listed are mostly found in binary data and
+// rank lowest.
+var byteRank = func() (rank [256]int) {
+	const common = "etaoinsrhldcumfpgwybvkxjqz"
+	for i := 0; i < len(common); i++ {
+		rank[common[i]] = 2*len(common) - i
+		rank[common[i]-'a'+'A'] = len(common) - i
+	}
+	for _, b := range []byte(" \t\r\n0123456789.,:;/\"'<>=_-") {
+		rank[b] = len(common)
+	}
+	rank[0xFF] = 3 * len(common)
+	rank[0x00] = 4 * len(common)
+	return rank
+}()
+
+// patternAnchor is the longest run of exact bytes in a pattern. Candidates
+// are found by searching for the anchor alone, and the whole pattern is only
+// compared where it occurs.
+type patternAnchor struct {
+	// offset is the position of the anchor in the pattern
+	offset  int
+	literal []byte
+	// folded is the literal with ASCII letters in upper case
+	folded []byte
+	// foldable is set if folding changes the literal, so a case-insensitive
+	// search cannot use bytes.Index
+	foldable bool
+	// rare is the position in the literal of its least common byte counting
+	// both cases of letters, which a case-insensitive search looks for
+	rare int
+}
+
+// newPatternAnchor picks the longest run of exact bytes of a pattern, or returns
+// nil if every byte of the pattern has wildcard bits
+func newPatternAnchor(patternBytes []byte, masks []byte) *patternAnchor {
+	bestStart, bestLength := 0, 0
+	for start := 0; start < len(patternBytes); {
+		if masks[start] != 0xFF {
+			start++
+			continue
+		}
+		end := start
+		for end < len(patternBytes) && masks[end] == 0xFF {
+			end++
+		}
+		if end-start > bestLength {
+			bestStart, bestLength = start, end-start
+		}
+		start = end
+	}
+	if bestLength == 0 {
+		return nil
+	}
+
+	a := &patternAnchor{
+		offset:  bestStart,
+		literal: patternBytes[bestStart : bestStart+bestLength],
+		folded:  make([]byte, bestLength),
+	}
+	for i, b := range a.literal {
+		a.folded[i] = foldTable[b]
+		if foldTable[b] != b || ('A' <= b && b <= 'Z') {
+			a.foldable = true
+		}
+		if foldedRank(b) < foldedRank(a.literal[a.rare]) {
+			a.rare = i
+		}
+	}
+	return a
+}
+
+// foldedRank is the byteRank of a byte counting both of its cases
+func foldedRank(b byte) int {
+	upper := foldTable[b]
+	if lower := lowerCase(upper); lower != upper {
+		return byteRank[upper] + byteRank[lower]
+	}
+	return byteRank[upper]
+}
+
+// lowerCase maps ASCII uppercase letters to lowercase and every other byte to itself
+func lowerCase(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// find calls yield with the position of every occurrence of the anchor in
+// window, in ascending order
+func (a *patternAnchor) find(window []byte, ignoreCase bool, yield func(pos int)) {
+	if !ignoreCase || !a.foldable {
+		for i := 0; i <= len(window)-len(a.literal); {
+			k := bytes.Index(window[i:], a.literal)
+			if k < 0 {
+				return
+			}
+			yield(i + k)
+			i += k + 1
+		}
+		return
+	}
+
+	// Look for the rare byte in both cases with bytes.IndexByte, which is much
+	// faster than folding every byte, and compare the whole anchor where either
+	// case occurs. The rare byte is only looked for where the anchor fits
+	// around it.
+	m := len(a.folded)
+	upper := a.folded[a.rare]
+	lower := lowerCase(upper)
+	end := max(len(window)-(m-1-a.rare), 0)
+	nextUpper := indexByteFrom(window[:end], a.rare, upper)
+	nextLower := nextUpper
+	if lower != upper {
+		nextLower = indexByteFrom(window[:end], a.rare, lower)
+	}
+	for {
+		pos := min(nextUpper, nextLower)
+		if pos >= end {
+			return
+		}
+		if start := pos - a.rare; equalFold(window[start:start+m], a.folded) {
+			yield(start)
+		}
+		if nextUpper == pos {
+			nextUpper = indexByteFrom(window[:end], pos+1, upper)
+		}
+		if nextLower == pos {
+			nextLower = indexByteFrom(window[:end], pos+1, lower)
+		}
+	}
+}
+
+// indexByteFrom returns the position of the first c in data at or after from,
+// or len(data) if there is none
+func indexByteFrom(data []byte, from int, c byte) int {
+	if from >= len(data) {
+		return len(data)
+	}
+	if k := bytes.IndexByte(data[from:], c); k >= 0 {
+		return from + k
+	}
+	return len(data)
+}
+
+// equalFold reports whether data equals the already folded bytes, ignoring ASCII case
+func equalFold(data, folded []byte) bool {
+	for i, b := range data {
+		if foldTable[b] != folded[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,9 +1,22 @@
 package memoryscanner
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 )
 
 func TestFindProcessesByName(t *testing.T) {
@@ -22,6 +35,142 @@ func TestFindProcessesByName(t *testing.T) {
 	t.Logf("找到 %d 个WeChatAppEx.exe进程: %v", len(pids), pids)
 }
 
+func TestProcessErrors(t *testing.T) {
+	// 找不到进程时返回 ErrProcessNotFound
+	if _, err := FindProcessesByName("memoryscanner-no-such-process.exe"); !errors.Is(err, ErrProcessNotFound) {
+		t.Errorf("FindProcessesByName error = %v, want %v", err, ErrProcessNotFound)
+	}
+
+	// 不存在的进程ID
+	_, err := NewScanner(0x7FFFFFF0)
+	if !errors.Is(err, ErrProcessNotFound) {
+		t.Errorf("NewScanner error = %v, want %v", err, ErrProcessNotFound)
+	}
+	var processErr *ProcessError
+	if !errors.As(err, &processErr) || processErr.PID != 0x7FFFFFF0 {
+		t.Errorf("NewScanner error = %#v, want a *ProcessError for the PID", err)
+	}
+}
+
+// 读取若干次后报告进程已退出的内存源
+type exitingSource struct {
+	*FakeSource
+	reads atomic.Int32
+}
+
+func (e *exitingSource) ReadAt(p []byte, addr Address) (int, error) {
+	if e.reads.Add(1) > 3 {
+		return 0, &ProcessError{PID: 1234, Kind: ErrProcessExited, Err: errors.New("no such process")}
+	}
+	return e.FakeSource.ReadAt(p, addr)
+}
+
+func TestScannerProcessExited(t *testing.T) {
+	for _, workers := range []int{0, 4} {
+		t.Run(fmt.Sprintf("workers %d", workers), func(t *testing.T) {
+			scanner := NewScannerFromSource(&exitingSource{FakeSource: newTestParallelSource()})
+			err := scanner.Scan(context.Background(), ScanOptions{
+				Pattern:    StringToPattern("WeChat", 0),
+				MaxAddress: 0x7FFFFFFFFFFF,
+				ChunkSize:  0x1000,
+				Workers:    workers,
+				Handler:    func(match Match) bool { return true },
+			})
+			if !errors.Is(err, ErrProcessExited) {
+				t.Errorf("Scan error = %v, want %v", err, ErrProcessExited)
+			}
+		})
+	}
+}
+
+// 没有读取权限的内存源，例如受 ptrace_scope 限制的进程
+type deniedSource struct {
+	*FakeSource
+	reads atomic.Int32
+}
+
+func (d *deniedSource) ReadAt(p []byte, addr Address) (int, error) {
+	d.reads.Add(1)
+	return 0, &ProcessError{PID: 1234, Kind: ErrAccessDenied, Err: errors.New("operation not permitted")}
+}
+
+func TestScannerAccessDenied(t *testing.T) {
+	// 拒绝访问时扫描立即失败，而不是把每一页都当作不可读
+	for _, workers := range []int{0, 4} {
+		t.Run(fmt.Sprintf("workers %d", workers), func(t *testing.T) {
+			source := &deniedSource{FakeSource: newTestParallelSource()}
+			scanner := NewScannerFromSource(source)
+			var stats ScanStats
+			err := scanner.Scan(context.Background(), ScanOptions{
+				Pattern:    StringToPattern("WeChat", 0),
+				MaxAddress: 0x7FFFFFFFFFFF,
+				ChunkSize:  0x1000,
+				Workers:    workers,
+				Stats:      &stats,
+				Handler:    func(match Match) bool { return true },
+			})
+			if !errors.Is(err, ErrAccessDenied) {
+				t.Errorf("Scan error = %v, want %v", err, ErrAccessDenied)
+			}
+			if reads := int(source.reads.Load()); reads > max(workers, 1) {
+				t.Errorf("ReadAt called %d times, want at most %d", reads, max(workers, 1))
+			}
+			if stats.BytesUnreadable != 0 {
+				t.Errorf("BytesUnreadable = %d, want 0", stats.BytesUnreadable)
+			}
+		})
+	}
+}
+
+func TestPatternError(t *testing.T) {
+	tests := []struct {
+		pattern string
+		pos     int
+		token   string
+	}{
+		{"", 0, ""},
+		{"48 ZZ 65", 3, "ZZ"},
+		{"48  65\t123", 7, "123"},
+		{"?? 4", 3, "4"},
+		{"48 4?? 65", 3, "4??"},
+		{"48 G?", 3, "G?"},
+		{"48 E8&F", 3, "E8&F"},
+		{"48 &F0", 3, "&F0"},
+		{"48 E8&F0&0F", 3, "E8&F0&0F"},
+		{"48 (4C|8B", 3, "("},
+		{"48 8B) 65", 5, ")"},
+		{"48 | 65", 3, "|"},
+		{"(48||4C)", 4, "|"},
+		{"48 (4C|)", 7, ")"},
+		{"48 [8-2] 65", 3, "[8-2]"},
+		{"48 [x] 65", 3, "[x]"},
+		{"48 [2-", 3, "[2-"},
+		{"48 [99999999] 65", 3, "[99999999]"},
+		{"(4X|4C)", 1, "4X"},
+		{"[0-4]", 0, ""},
+	}
+
+	for _, tt := range tests {
+		_, err := NewPatternMatcher(tt.pattern)
+		if !errors.Is(err, ErrInvalidPattern) {
+			t.Errorf("NewPatternMatcher(%q) error = %v, want %v", tt.pattern, err, ErrInvalidPattern)
+			continue
+		}
+		var patternErr *PatternError
+		if !errors.As(err, &patternErr) || patternErr.Pos != tt.pos || patternErr.Token != tt.token {
+			t.Errorf("NewPatternMatcher(%q) error = %#v, want position %d token %q", tt.pattern, err, tt.pos, tt.token)
+		}
+	}
+
+	// Scan 返回同样的错误
+	scanner := NewScannerFromSource(newTestFakeSource())
+	defer scanner.Close()
+	err := scanner.Scan(context.Background(), ScanOptions{Pattern: "57 GG", Handler: func(match Match) bool { return true }})
+	if !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("Scan error = %v, want %v", err, ErrInvalidPattern)
+	}
+}
+
 func TestStringToPattern(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -92,6 +241,133 @@ func TestPatternMatcher(t *testing.T) {
 	}
 }
 
+func TestPatternMatcherMasks(t *testing.T) {
+	// 测试半字节通配符和位掩码
+	data := []byte{0x48, 0x8B, 0x05, 0xE8, 0x12, 0x4C, 0x8B, 0x0D, 0xE9, 0x34, 0x41, 0x61}
+	tests := []struct {
+		pattern    string
+		ignoreCase bool
+		want       []int
+	}{
+		{"4? 8B", false, []int{0, 5}},
+		{"?8 8B", false, []int{0}},
+		{"?C 8B 0D", false, []int{5}},
+		{"E8&FE", false, []int{3, 8}},
+		{"8B 05&0F", false, []int{1}},
+		{"8B 0? E8&F0", false, []int{1, 6}},
+		{"4? 8B 0?", false, []int{0, 5}},
+		{"40&F0", false, []int{0, 5, 10}},
+		// 掩码字节不区分大小写，只有完整字节才忽略大小写
+		{"41 41", true, []int{10}},
+		{"41 4?", true, nil},
+		{"61&FF", true, []int{10, 11}},
+		{"61&FE", true, []int{11}},
+	}
+
+	for _, tt := range tests {
+		matcher, err := NewPatternMatcher(tt.pattern)
+		if err != nil {
+			t.Fatalf("NewPatternMatcher(%q) failed: %v", tt.pattern, err)
+		}
+		if got := matcher.FindMatches(data, tt.ignoreCase); !slices.Equal(got, tt.want) {
+			t.Errorf("FindMatches(%q, ignoreCase=%v) = %v, want %v", tt.pattern, tt.ignoreCase, got, tt.want)
+		}
+	}
+}
+
+func TestPatternMatcherVariable(t *testing.T) {
+	// 测试跳转和分支
+	data := []byte{0x48, 0x8B, 0x05, 0x90, 0x4C, 0x8B, 0x0D, 0xE8, 0x01, 0x02, 0x03, 0xC3}
+	tests := []struct {
+		pattern string
+		want    []MatchSpan
+	}{
+		{"(48|4C) 8B", []MatchSpan{{0, 2}, {4, 6}}},
+		{"(48 8B|4C) 8B", []MatchSpan{{4, 6}}},
+		{"8B [1-3] E8", []MatchSpan{{5, 8}}},
+		{"8B [0-8] C3", []MatchSpan{{5, 12}}},
+		{"E8 [2]", []MatchSpan{{7, 10}}},
+		{"(E8|90) [0-2] (01|8B)", []MatchSpan{{3, 6}, {7, 9}}},
+		{"((48|4C) 8B|E8) ??", []MatchSpan{{0, 3}, {4, 7}, {7, 9}}},
+		{"01 [0-4]", []MatchSpan{{8, 9}}},
+		{"(01|01 02 03) C3", []MatchSpan{{8, 12}}},
+		{"(4?|E8) ?? (05|0D)", []MatchSpan{{0, 3}, {4, 7}}},
+		// 数据末尾不足的跳转不会匹配
+		{"02 [3-4] C3", nil},
+	}
+
+	for _, tt := range tests {
+		matcher, err := NewPatternMatcher(tt.pattern)
+		if err != nil {
+			t.Fatalf("NewPatternMatcher(%q) failed: %v", tt.pattern, err)
+		}
+		if got := matcher.FindSpans(data, false); !slices.Equal(got, tt.want) {
+			t.Errorf("FindSpans(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestPatternMatcherJumpsOnZeros(t *testing.T) {
+	// 连续多个跳转在全零数据上不会指数级回溯，只有末尾的 FF 附近有匹配
+	data := make([]byte, 64<<10)
+	data[len(data)-1] = 0xFF
+
+	tests := []struct {
+		pattern string
+		want    int
+	}{
+		{"00 [0-255] 00 [0-255] FF", 511},
+		{"00 [0-64] 00 [0-64] 00 [0-64] FF", 193},
+		{"(00|00 00) [0-255] (00 00|00) [0-255] FF", 513},
+		{"00 [0-65536] 00 [0-65536] 01", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			matcher, err := NewPatternMatcher(tt.pattern)
+			if err != nil {
+				t.Fatalf("NewPatternMatcher failed: %v", err)
+			}
+			start := time.Now()
+			matches := matcher.FindMatches(data, false)
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("FindMatches took %v", elapsed)
+			}
+			if len(matches) != tt.want {
+				t.Errorf("FindMatches found %d matches, want %d", len(matches), tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternLength(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		min, max int
+	}{
+		{"48 8B 05", 3, 3},
+		{"48 [2] 05", 4, 4},
+		{"48 [2-8] (01|02 03)", 4, 11},
+		{"((48|4C 8B) [0-2]|E8) C3", 2, 5},
+	}
+
+	for _, tt := range tests {
+		matcher, err := NewPatternMatcher(tt.pattern)
+		if err != nil {
+			t.Fatalf("NewPatternMatcher(%q) failed: %v", tt.pattern, err)
+		}
+		if got := matcher.GetMinPatternLength(); got != tt.min {
+			t.Errorf("GetMinPatternLength(%q) = %d, want %d", tt.pattern, got, tt.min)
+		}
+		if got := matcher.GetMaxPatternLength(); got != tt.max {
+			t.Errorf("GetMaxPatternLength(%q) = %d, want %d", tt.pattern, got, tt.max)
+		}
+		if got := matcher.GetPatternLength(); got != tt.max {
+			t.Errorf("GetPatternLength(%q) = %d, want %d", tt.pattern, got, tt.max)
+		}
+	}
+}
+
 func TestScanner(t *testing.T) {
 	// 首先查找WeChatAppEx.exe进程
 	pids, err := FindProcessesByName("WeChatAppEx.exe")
@@ -159,82 +435,1756 @@ func TestScanner(t *testing.T) {
 	}
 }
 
-func TestAddressString(t *testing.T) {
+// 保存自身扫描测试的缓冲区，使其分配在堆上（栈上的数据在栈增长时会被移动）
+var selfTestBuffer []byte
+
+func TestScannerSelf(t *testing.T) {
+	// 扫描当前测试进程自身的内存，不依赖外部进程
+	marker := []byte("memoryscanner-self-test-marker")
+	buffer := make([]byte, 4096)
+	selfTestBuffer = buffer
+	copy(buffer[1000:], marker)
+	want := Address(uintptr(unsafe.Pointer(&buffer[1000])))
+
+	scanner, err := NewScanner(uint32(os.Getpid()))
+	if err != nil {
+		t.Skipf("无法打开当前进程: %v", err)
+	}
+	defer scanner.Close()
+
+	found := false
+	err = scanner.Scan(context.Background(), ScanOptions{
+		Pattern:    StringToPattern(string(marker), 0),
+		MinAddress: 0x0,
+		MaxAddress: 0x7FFFFFFFFFFF,
+		Handler: func(match Match) bool {
+			if match.Address == want {
+				found = true
+				return false
+			}
+			return true
+		},
+	})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	runtime.KeepAlive(buffer)
+
+	if !found {
+		t.Errorf("marker at %s not found", want)
+	}
+}
+
+// 辅助函数：构造指定大小的区域数据，并在给定偏移处写入内容
+func makeRegionData(size int, contents map[int]string) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte('a' + i%26)
+	}
+	for offset, content := range contents {
+		copy(data[offset:], content)
+	}
+	return data
+}
+
+// 辅助函数：构造用于扫描测试的假进程
+func newTestFakeSource() *FakeSource {
+	return NewFakeSource(
+		FakeRegion{
+			BaseAddress: 0x10000,
+			Data:        makeRegionData(0x1000, map[int]string{0x10: "WeChat", 0x800: "WECHAT"}),
+			Protection:  ProtectRead | ProtectWrite,
+			Type:        TypePrivate,
+		},
+		FakeRegion{
+			// 不可访问的区域应被跳过
+			BaseAddress: 0x20000,
+			Data:        makeRegionData(0x1000, map[int]string{0x0: "WeChat"}),
+			Type:        TypePrivate,
+		},
+		FakeRegion{
+			// 仅保留未提交的区域应被跳过
+			BaseAddress: 0x30000,
+			Data:        makeRegionData(0x1000, map[int]string{0x0: "WeChat"}),
+			Protection:  ProtectRead,
+			State:       StateReserve,
+		},
+		FakeRegion{
+			BaseAddress: 0x40000,
+			Data:        makeRegionData(0x1000, map[int]string{0x100: "wechat"}),
+			Protection:  ProtectRead | ProtectExecute,
+			Type:        TypeImage,
+			Name:        "WeChatAppEx.exe",
+		},
+	)
+}
+
+// 辅助函数：扫描并返回所有匹配地址
+func scanAddresses(t *testing.T, scanner *Scanner, opts ScanOptions) []Address {
+	t.Helper()
+
+	var addresses []Address
+	opts.Handler = func(match Match) bool {
+		addresses = append(addresses, match.Address)
+		return true
+	}
+	if err := scanner.Scan(context.Background(), opts); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	return addresses
+}
+
+func TestScannerFakeSource(t *testing.T) {
 	tests := []struct {
-		input    Address
-		expected string
+		name       string
+		minAddress Address
+		maxAddress Address
+		expected   []Address
 	}{
-		{0x0, "0x0"},
-		{0x1234, "0x1234"},
-		{0x7FFFFFFFFFFF, "0x7FFFFFFFFFFF"},
+		{
+			name:       "full range",
+			minAddress: 0x0,
+			maxAddress: 0x7FFFFFFFFFFF,
+			expected:   []Address{0x10010, 0x10800, 0x40100},
+		},
+		{
+			name:       "min address inside region",
+			minAddress: 0x10011,
+			maxAddress: 0x7FFFFFFFFFFF,
+			expected:   []Address{0x10800, 0x40100},
+		},
+		{
+			name:       "max address before last region",
+			minAddress: 0x0,
+			maxAddress: 0x40000,
+			expected:   []Address{0x10010, 0x10800},
+		},
+		{
+			name:       "match cut by max address",
+			minAddress: 0x10000,
+			maxAddress: 0x10014,
+			expected:   nil,
+		},
 	}
 
 	for _, tt := range tests {
-		t.Run("", func(t *testing.T) {
-			result := tt.input.String()
-			if result != tt.expected {
-				t.Errorf("Address(%d).String() = %q, want %q", tt.input, result, tt.expected)
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := NewScannerFromSource(newTestFakeSource())
+			defer scanner.Close()
+
+			result := scanAddresses(t, scanner, ScanOptions{
+				Pattern:    StringToPattern("WeChat", 0),
+				IgnoreCase: true,
+				MinAddress: tt.minAddress,
+				MaxAddress: tt.maxAddress,
+			})
+			if !slices.Equal(result, tt.expected) {
+				t.Errorf("Scan addresses = %v, want %v", result, tt.expected)
 			}
 		})
 	}
 }
 
-func TestMatchContent(t *testing.T) {
+func TestScannerRegions(t *testing.T) {
+	scanner := NewScannerFromSource(newTestFakeSource())
+	defer scanner.Close()
+
+	regions, err := scanner.Regions(context.Background())
+	if err != nil {
+		t.Fatalf("Regions failed: %v", err)
+	}
+	if len(regions) != 4 {
+		t.Fatalf("Expected 4 regions, got %d", len(regions))
+	}
+	if regions[3].Name != "WeChatAppEx.exe" || regions[3].Type != TypeImage {
+		t.Errorf("regions[3] = %+v, want image region of WeChatAppEx.exe", regions[3])
+	}
+
+	// 只扫描调用者挑选的区域
+	result := scanAddresses(t, scanner, ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		IgnoreCase: true,
+		MaxAddress: 0x7FFFFFFFFFFF,
+		Regions:    regions[3:],
+	})
+	if want := []Address{0x40100}; !slices.Equal(result, want) {
+		t.Errorf("Scan addresses = %v, want %v", result, want)
+	}
+}
+
+func TestScannerRegionFilter(t *testing.T) {
 	tests := []struct {
 		name     string
-		data     []byte
-		expected string
+		filter   RegionFilter
+		expected []Address
 	}{
-		{
-			name:     "valid UTF-8",
-			data:     []byte("Hello World"),
-			expected: "Hello World",
-		},
-		{
-			name:     "empty data",
-			data:     []byte{},
-			expected: "",
-		},
+		{name: "no filter", filter: RegionFilter{}, expected: []Address{0x10010, 0x10800, 0x40100}},
+		{name: "writable only", filter: RegionFilter{RequireProtection: ProtectWrite}, expected: []Address{0x10010, 0x10800}},
+		{name: "exclude executable", filter: RegionFilter{ExcludeProtection: ProtectExecute}, expected: []Address{0x10010, 0x10800}},
+		{name: "executable only", filter: RegionFilter{RequireProtection: ProtectExecute}, expected: []Address{0x40100}},
+		{name: "image only", filter: RegionFilter{Types: TypeImage}, expected: []Address{0x40100}},
+		{name: "private or mapped", filter: RegionFilter{Types: TypePrivate | TypeMapped}, expected: []Address{0x10010, 0x10800}},
+		{name: "include module glob", filter: RegionFilter{IncludeNames: []string{"*.EXE"}}, expected: []Address{0x40100}},
+		{name: "exclude module", filter: RegionFilter{ExcludeNames: []string{`C:\WeChat\wechatappex.exe`, "wechatappex.exe"}}, expected: []Address{0x10010, 0x10800}},
+		{name: "min size", filter: RegionFilter{MinSize: 0x2000}, expected: nil},
+		{name: "max size", filter: RegionFilter{MaxSize: 0x1000}, expected: []Address{0x10010, 0x10800, 0x40100}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			match := Match{Data: tt.data}
-			result := match.Content()
-			if result != tt.expected {
-				t.Errorf("Match.Content() = %q, want %q", result, tt.expected)
+			scanner := NewScannerFromSource(newTestFakeSource())
+			defer scanner.Close()
+
+			result := scanAddresses(t, scanner, ScanOptions{
+				Pattern:    StringToPattern("WeChat", 0),
+				IgnoreCase: true,
+				MaxAddress: 0x7FFFFFFFFFFF,
+				Filter:     tt.filter,
+			})
+			if !slices.Equal(result, tt.expected) {
+				t.Errorf("Scan addresses = %v, want %v", result, tt.expected)
 			}
 		})
 	}
 }
 
-// 辅助函数：截断字符串用于测试显示
-func truncateStringForTest(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+func TestRegionFilterMatchName(t *testing.T) {
+	region := Region{Name: `C:\Program Files\Tencent\WeChat\WeChatAppEx.exe`}
+	tests := []struct {
+		pattern  string
+		expected bool
+	}{
+		{pattern: "WeChatAppEx.exe", expected: true},
+		{pattern: "wechat*.exe", expected: true},
+		{pattern: `c:\program files\tencent\*\*.exe`, expected: true},
+		{pattern: "*.dll", expected: false},
 	}
-	if maxLen <= 3 {
-		return s[:maxLen]
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			filter := RegionFilter{IncludeNames: []string{tt.pattern}}
+			if result := filter.Match(region); result != tt.expected {
+				t.Errorf("Match(%q) = %v, want %v", tt.pattern, result, tt.expected)
+			}
+		})
+	}
+
+	// 无效的模式应在扫描开始前报错
+	scanner := NewScannerFromSource(newTestFakeSource())
+	defer scanner.Close()
+	err := scanner.Scan(context.Background(), ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		MaxAddress: 0x7FFFFFFFFFFF,
+		Filter:     RegionFilter{ExcludeNames: []string{"["}},
+		Handler:    func(match Match) bool { return true },
+	})
+	if err == nil {
+		t.Error("Scan with invalid name pattern succeeded, want error")
 	}
-	return s[:maxLen-3] + "..."
 }
 
-// 基准测试：模式匹配性能
-func BenchmarkPatternMatcher(b *testing.B) {
-	pattern := "57 65 43 68 61 74"
-	matcher, err := NewPatternMatcher(pattern)
+func TestScannerChunkBoundaries(t *testing.T) {
+	// 匹配项跨越分块边界时应恰好被报告一次
+	offsets := []int{0, 10, 32, 45, 100, 994}
+	contents := make(map[int]string)
+	var expected []Address
+	for _, offset := range offsets {
+		contents[offset] = "WeChat"
+		expected = append(expected, Address(0x10000+offset))
+	}
+	source := NewFakeSource(FakeRegion{
+		BaseAddress: 0x10000,
+		Data:        makeRegionData(1000, contents),
+		Protection:  ProtectRead | ProtectWrite,
+	})
+
+	for _, chunkSize := range []int{1, 5, 7, 16, 33, 4096} {
+		t.Run(fmt.Sprintf("chunk %d", chunkSize), func(t *testing.T) {
+			scanner := NewScannerFromSource(source)
+
+			result := scanAddresses(t, scanner, ScanOptions{
+				Pattern:    StringToPattern("WeChat", 0),
+				MaxAddress: 0x7FFFFFFFFFFF,
+				ChunkSize:  chunkSize,
+			})
+			if !slices.Equal(result, expected) {
+				t.Errorf("Scan addresses = %v, want %v", result, expected)
+			}
+		})
+	}
+}
+
+func TestScannerUnreadablePages(t *testing.T) {
+	// 区域中间有两页不可读，前后的可读页仍应被扫描
+	source := NewFakeSource(FakeRegion{
+		BaseAddress: 0x10000,
+		Data:        makeRegionData(0x6000, map[int]string{0x100: "WeChat", 0x1FFD: "WeChat", 0x3000: "WeChat", 0x5FFA: "WeChat"}),
+		Protection:  ProtectRead | ProtectWrite,
+		Unreadable:  []AddressRange{{Start: 0x12000, End: 0x13000}, {Start: 0x11000, End: 0x12000}},
+	})
+	expectedMatches := []Address{0x10100, 0x13000, 0x15FFA}
+	expectedHoles := []AddressRange{{Start: 0x11000, End: 0x13000}}
+
+	for _, chunkSize := range []int{0x300, 0x1000, 0x1800, 0} {
+		t.Run(fmt.Sprintf("chunk %d", chunkSize), func(t *testing.T) {
+			scanner := NewScannerFromSource(source)
+
+			var holes []AddressRange
+			result := scanAddresses(t, scanner, ScanOptions{
+				Pattern:    StringToPattern("WeChat", 0),
+				MaxAddress: 0x7FFFFFFFFFFF,
+				ChunkSize:  chunkSize,
+				UnreadableHandler: func(r AddressRange) {
+					holes = append(holes, r)
+				},
+			})
+			if !slices.Equal(result, expectedMatches) {
+				t.Errorf("Scan addresses = %v, want %v", result, expectedMatches)
+			}
+			if !slices.Equal(holes, expectedHoles) {
+				t.Errorf("Unreadable ranges = %v, want %v", holes, expectedHoles)
+			}
+		})
+	}
+}
+
+func TestScannerHandlerStop(t *testing.T) {
+	// 处理函数返回false后不应再扫描后续区域
+	scanner := NewScannerFromSource(newTestFakeSource())
+	defer scanner.Close()
+
+	matchCount := 0
+	err := scanner.Scan(context.Background(), ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		IgnoreCase: true,
+		MaxAddress: 0x7FFFFFFFFFFF,
+		Handler: func(match Match) bool {
+			matchCount++
+			return false
+		},
+	})
 	if err != nil {
-		b.Fatalf("NewPatternMatcher failed: %v", err)
+		t.Fatalf("Scan failed: %v", err)
 	}
+	if matchCount != 1 {
+		t.Errorf("Expected 1 match before stop, got %d", matchCount)
+	}
+}
 
-	// 创建测试数据 (1KB)
-	data := make([]byte, 1024)
-	for i := range data {
-		data[i] = byte(i % 256)
+func TestScannerCancelled(t *testing.T) {
+	scanner := NewScannerFromSource(newTestFakeSource())
+	defer scanner.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := scanner.Scan(ctx, ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		MaxAddress: 0x7FFFFFFFFFFF,
+		Handler:    func(match Match) bool { return true },
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Scan error = %v, want %v", err, context.Canceled)
 	}
+}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		matcher.FindMatches(data, false)
+// 辅助函数：构造包含多个区域、大量匹配和不可读页的内存源
+func newTestParallelSource() *FakeSource {
+	var regions []FakeRegion
+	for i := 0; i < 16; i++ {
+		contents := map[int]string{}
+		for offset := 0x10 * i; offset < 0x8000; offset += 0x40 {
+			contents[offset] = "WeChat"
+		}
+		region := FakeRegion{
+			BaseAddress: Address(0x100000 * (i + 1)),
+			Data:        makeRegionData(0x8000, contents),
+			Protection:  ProtectRead | ProtectWrite,
+		}
+		if i%4 == 0 {
+			region.Unreadable = []AddressRange{{Start: region.BaseAddress + 0x2000, End: region.BaseAddress + 0x3000}}
+		}
+		regions = append(regions, region)
+	}
+	return NewFakeSource(regions...)
+}
+
+func TestScannerParallel(t *testing.T) {
+	scanner := NewScannerFromSource(newTestParallelSource())
+	defer scanner.Close()
+
+	scanWith := func(workers int, ordered bool) ([]Address, []AddressRange) {
+		var addresses []Address
+		var holes []AddressRange
+		err := scanner.Scan(context.Background(), ScanOptions{
+			Pattern:    StringToPattern("WeChat", 0),
+			MaxAddress: 0x7FFFFFFFFFFF,
+			ChunkSize:  0x1000,
+			Workers:    workers,
+			Ordered:    ordered,
+			Handler: func(match Match) bool {
+				addresses = append(addresses, match.Address)
+				return true
+			},
+			UnreadableHandler: func(r AddressRange) {
+				holes = append(holes, r)
+			},
+		})
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		return addresses, holes
+	}
+
+	wantAddresses, wantHoles := scanWith(0, false)
+	if len(wantAddresses) < scanBatchSize || len(wantHoles) != 4 {
+		t.Fatalf("sequential scan found %d matches and %d holes", len(wantAddresses), len(wantHoles))
 	}
-}
\ No newline at end of file
+
+	// 有序模式的结果与顺序扫描完全一致
+	addresses, holes := scanWith(4, true)
+	if !slices.Equal(addresses, wantAddresses) {
+		t.Errorf("ordered parallel scan found %d matches, want %d in the same order", len(addresses), len(wantAddresses))
+	}
+	if !slices.Equal(holes, wantHoles) {
+		t.Errorf("ordered parallel unreadable ranges = %v, want %v", holes, wantHoles)
+	}
+
+	// 无序模式找到相同的匹配
+	addresses, holes = scanWith(4, false)
+	slices.Sort(addresses)
+	slices.SortFunc(holes, func(a, b AddressRange) int { return cmp.Compare(a.Start, b.Start) })
+	if !slices.Equal(addresses, wantAddresses) {
+		t.Errorf("unordered parallel scan found %d matches, want %d", len(addresses), len(wantAddresses))
+	}
+	if !slices.Equal(holes, wantHoles) {
+		t.Errorf("unordered parallel unreadable ranges = %v, want %v", holes, wantHoles)
+	}
+}
+
+func TestScannerParallelStop(t *testing.T) {
+	// 处理函数返回false或取消上下文后，所有工作协程都应停止
+	scanner := NewScannerFromSource(newTestParallelSource())
+	defer scanner.Close()
+
+	for _, ordered := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ordered %v", ordered), func(t *testing.T) {
+			matchCount := 0
+			err := scanner.Scan(context.Background(), ScanOptions{
+				Pattern:    StringToPattern("WeChat", 0),
+				MaxAddress: 0x7FFFFFFFFFFF,
+				Workers:    4,
+				Ordered:    ordered,
+				Handler: func(match Match) bool {
+					matchCount++
+					return matchCount < 10
+				},
+			})
+			if err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+			if matchCount != 10 {
+				t.Errorf("Expected 10 matches before stop, got %d", matchCount)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			matchCount = 0
+			err = scanner.Scan(ctx, ScanOptions{
+				Pattern:    StringToPattern("WeChat", 0),
+				MaxAddress: 0x7FFFFFFFFFFF,
+				Workers:    4,
+				Ordered:    ordered,
+				Handler: func(match Match) bool {
+					matchCount++
+					if matchCount == 10 {
+						cancel()
+					}
+					return true
+				},
+			})
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("Scan error = %v, want %v", err, context.Canceled)
+			}
+			if matchCount != 10 {
+				t.Errorf("Expected 10 matches before cancel, got %d", matchCount)
+			}
+		})
+	}
+}
+
+// 记录并发读取数和最大读取长度的内存源
+type countingSource struct {
+	*FakeSource
+	mu         sync.Mutex
+	active     int
+	maxActive  int
+	maxReadLen int
+}
+
+func (c *countingSource) ReadAt(p []byte, addr Address) (int, error) {
+	c.mu.Lock()
+	c.active++
+	c.maxActive = max(c.maxActive, c.active)
+	c.maxReadLen = max(c.maxReadLen, len(p))
+	c.mu.Unlock()
+
+	// 让读取持续一段时间，以便观察并发
+	time.Sleep(100 * time.Microsecond)
+	n, err := c.FakeSource.ReadAt(p, addr)
+
+	c.mu.Lock()
+	c.active--
+	c.mu.Unlock()
+	return n, err
+}
+
+func TestScannerMemoryBudget(t *testing.T) {
+	want := scanAddresses(t, NewScannerFromSource(newTestParallelSource()), ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		MaxAddress: 0x7FFFFFFFFFFF,
+	})
+
+	tests := []struct {
+		name       string
+		budget     int
+		chunkSize  int
+		maxActive  int
+		maxReadLen int
+	}{
+		// 预算只够两个缓冲区，同时最多两个读取
+		{"two buffers", 0x2100, 0x1000, 2, 0x1005},
+		// 预算小于一个块，块大小被缩小
+		{"shrunk chunk", 0x1800, 0, 1, 0x1800},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &countingSource{FakeSource: newTestParallelSource()}
+			var addresses []Address
+			err := NewScannerFromSource(source).Scan(context.Background(), ScanOptions{
+				Pattern:      StringToPattern("WeChat", 0),
+				MaxAddress:   0x7FFFFFFFFFFF,
+				ChunkSize:    tt.chunkSize,
+				Workers:      4,
+				Ordered:      true,
+				MemoryBudget: tt.budget,
+				Handler: func(match Match) bool {
+					addresses = append(addresses, match.Address)
+					return true
+				},
+			})
+			if err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+
+			if !slices.Equal(addresses, want) {
+				t.Errorf("Scan found %d matches, want %d in the same order", len(addresses), len(want))
+			}
+			if source.maxActive > tt.maxActive {
+				t.Errorf("concurrent reads = %d, want at most %d", source.maxActive, tt.maxActive)
+			}
+			if source.maxReadLen > tt.maxReadLen {
+				t.Errorf("largest read = %#x, want at most %#x", source.maxReadLen, tt.maxReadLen)
+			}
+		})
+	}
+}
+
+func TestScannerMatchData(t *testing.T) {
+	scanner := NewScannerFromSource(newTestParallelSource())
+	defer scanner.Close()
+
+	// 默认情况下 Data 可以在扫描结束后保留，且互不影响
+	var matches []Match
+	err := scanner.Scan(context.Background(), ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		MaxAddress: 0x7FFFFFFFFFFF,
+		ChunkSize:  0x1000,
+		Handler: func(match Match) bool {
+			matches = append(matches, match)
+			return true
+		},
+	})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	for _, match := range matches {
+		if string(match.Data) != "WeChat" || cap(match.Data) != len(match.Data) {
+			t.Fatalf("retained Data at %s = %q (cap %d), want %q", match.Address, match.Data, cap(match.Data), "WeChat")
+		}
+	}
+
+	// ReuseMatchData 时 Data 在处理函数中有效
+	count := 0
+	err = scanner.Scan(context.Background(), ScanOptions{
+		Pattern:        StringToPattern("WeChat", 0),
+		MaxAddress:     0x7FFFFFFFFFFF,
+		ReuseMatchData: true,
+		Handler: func(match Match) bool {
+			if string(match.Data) != "WeChat" {
+				t.Errorf("Data at %s = %q, want %q", match.Address, match.Data, "WeChat")
+			}
+			count++
+			return true
+		},
+	})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if count != len(matches) {
+		t.Errorf("ReuseMatchData scan found %d matches, want %d", count, len(matches))
+	}
+}
+
+func TestScannerStats(t *testing.T) {
+	source := NewFakeSource(
+		// 部分可读的区域
+		FakeRegion{
+			BaseAddress: 0x10000,
+			Data:        makeRegionData(0x2000, map[int]string{0x10: "WeChat", 0x1800: "WeChat"}),
+			Protection:  ProtectRead | ProtectWrite,
+			Type:        TypePrivate,
+			Unreadable:  []AddressRange{{Start: 0x11000, End: 0x12000}},
+		},
+		FakeRegion{BaseAddress: 0x20000, Data: make([]byte, 0x1000), Type: TypePrivate},
+		FakeRegion{BaseAddress: 0x30000, Data: make([]byte, 0x1000), State: StateReserve, Type: TypePrivate},
+		// 被过滤器排除的区域
+		FakeRegion{
+			BaseAddress: 0x40000,
+			Data:        makeRegionData(0x1000, map[int]string{0x100: "WeChat"}),
+			Protection:  ProtectRead | ProtectExecute,
+			Type:        TypeImage,
+		},
+		// 完全不可读的区域
+		FakeRegion{
+			BaseAddress: 0x50000,
+			Data:        makeRegionData(0x1000, map[int]string{0x100: "WeChat"}),
+			Protection:  ProtectRead,
+			Type:        TypePrivate,
+			Unreadable:  []AddressRange{{Start: 0x50000, End: 0x51000}},
+		},
+		FakeRegion{
+			BaseAddress: 0x60000,
+			Data:        makeRegionData(0x1000, map[int]string{0x200: "WeChat"}),
+			Protection:  ProtectRead | ProtectWrite,
+			Type:        TypePrivate,
+		},
+		// 地址范围之外的区域不计入统计
+		FakeRegion{BaseAddress: 0x900000, Data: make([]byte, 0x1000), Type: TypePrivate},
+	)
+	scanner := NewScannerFromSource(source)
+	defer scanner.Close()
+
+	want := ScanStats{
+		RegionsVisited:           2,
+		RegionsSkippedState:      1,
+		RegionsSkippedProtection: 1,
+		RegionsSkippedFilter:     1,
+		RegionsSkippedReadError:  1,
+		BytesScanned:             0x2000,
+		BytesUnreadable:          0x2000,
+		MatchesFound:             2,
+		MatchesDelivered:         2,
+	}
+
+	for _, workers := range []int{0, 4} {
+		t.Run(fmt.Sprintf("workers %d", workers), func(t *testing.T) {
+			var stats ScanStats
+			scanAddresses(t, scanner, ScanOptions{
+				Pattern:    StringToPattern("WeChat", 0),
+				MaxAddress: 0x800000,
+				Filter:     RegionFilter{Types: TypePrivate},
+				Workers:    workers,
+				Stats:      &stats,
+			})
+
+			if stats.Elapsed <= 0 {
+				t.Errorf("Elapsed = %v, want > 0", stats.Elapsed)
+			}
+			stats.Elapsed = 0
+			if stats != want {
+				t.Errorf("Stats = %+v, want %+v", stats, want)
+			}
+		})
+	}
+
+	// 提前停止时，已找到但未交付的匹配不计入 MatchesDelivered
+	var stats ScanStats
+	err := scanner.Scan(context.Background(), ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		MaxAddress: 0x800000,
+		Stats:      &stats,
+		Handler:    func(match Match) bool { return false },
+	})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if stats.MatchesDelivered != 1 || stats.MatchesFound != 1 {
+		t.Errorf("after stop MatchesFound = %d, MatchesDelivered = %d, want 1 and 1", stats.MatchesFound, stats.MatchesDelivered)
+	}
+}
+
+func TestScannerProgress(t *testing.T) {
+	scanner := NewScannerFromSource(newTestParallelSource())
+	defer scanner.Close()
+
+	for _, workers := range []int{0, 4} {
+		t.Run(fmt.Sprintf("workers %d", workers), func(t *testing.T) {
+			var reports []ScanProgress
+			matchCount := 0
+			err := scanner.Scan(context.Background(), ScanOptions{
+				Pattern:          StringToPattern("WeChat", 0),
+				MaxAddress:       0x7FFFFFFFFFFF,
+				ChunkSize:        0x1000,
+				Workers:          workers,
+				ProgressInterval: time.Nanosecond,
+				Handler: func(match Match) bool {
+					matchCount++
+					return true
+				},
+				Progress: func(progress ScanProgress) {
+					reports = append(reports, progress)
+				},
+			})
+			if err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+
+			if len(reports) == 0 {
+				t.Fatal("Progress was never called")
+			}
+			for i, report := range reports {
+				if report.TotalBytes != 16*0x8000 {
+					t.Fatalf("report %d TotalBytes = %#x, want %#x", i, report.TotalBytes, 16*0x8000)
+				}
+				if i > 0 && (report.BytesScanned < reports[i-1].BytesScanned || report.Matches < reports[i-1].Matches) {
+					t.Fatalf("report %d went backwards: %+v after %+v", i, report, reports[i-1])
+				}
+			}
+
+			// 最后一次报告表示扫描完成
+			last := reports[len(reports)-1]
+			if last.BytesScanned != last.TotalBytes || last.Percent() != 100 || last.Remaining() != 0 {
+				t.Errorf("last report = %+v, want all bytes scanned", last)
+			}
+			if last.Matches != matchCount {
+				t.Errorf("last report Matches = %d, want %d", last.Matches, matchCount)
+			}
+			if last.Region.BaseAddress == 0 {
+				t.Errorf("last report Region = %+v, want a scanned region", last.Region)
+			}
+		})
+	}
+}
+
+func TestScanProgressRemaining(t *testing.T) {
+	progress := ScanProgress{BytesScanned: 25, TotalBytes: 100, Elapsed: time.Second}
+	if got := progress.Percent(); got != 25 {
+		t.Errorf("Percent() = %v, want 25", got)
+	}
+	if got := progress.Remaining(); got != 3*time.Second {
+		t.Errorf("Remaining() = %v, want %v", got, 3*time.Second)
+	}
+}
+
+func TestScannerMatches(t *testing.T) {
+	scanner := NewScannerFromSource(newTestFakeSource())
+	defer scanner.Close()
+
+	opts := ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		IgnoreCase: true,
+		MaxAddress: 0x7FFFFFFFFFFF,
+	}
+
+	var result []Address
+	for match, err := range scanner.Matches(context.Background(), opts) {
+		if err != nil {
+			t.Fatalf("Matches failed: %v", err)
+		}
+		result = append(result, match.Address)
+	}
+	if want := []Address{0x10010, 0x10800, 0x40100}; !slices.Equal(result, want) {
+		t.Errorf("Matches addresses = %v, want %v", result, want)
+	}
+
+	// 跳出循环后扫描停止
+	count := 0
+	for range scanner.Matches(context.Background(), opts) {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("iterations after break = %d, want 1", count)
+	}
+
+	// 扫描失败时错误作为最后一个元素返回
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var errs []error
+	for match, err := range scanner.Matches(ctx, opts) {
+		if err == nil {
+			t.Errorf("unexpected match %v from a cancelled scan", match.Address)
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+		t.Errorf("Matches errors = %v, want [%v]", errs, context.Canceled)
+	}
+}
+
+func TestScannerMatchContext(t *testing.T) {
+	data := makeRegionData(0x4000, map[int]string{
+		0x2: "WeChat", 0xFFC: "WeChat", 0x1FF0: "WeChat", 0x3004: "WeChat", 0x3FF8: "WeChat",
+	})
+	fakeRegion := FakeRegion{
+		BaseAddress: 0x10000,
+		Data:        data,
+		Protection:  ProtectRead | ProtectWrite,
+		Type:        TypePrivate,
+		Name:        "heap",
+		Unreadable:  []AddressRange{{Start: 0x12000, End: 0x13000}},
+	}
+	scanner := NewScannerFromSource(NewFakeSource(fakeRegion))
+	defer scanner.Close()
+
+	// 上下文在区域边界和不可读页处截断
+	tests := []struct {
+		offset      int
+		beforeStart int
+		afterEnd    int
+	}{
+		{0x2, 0x0, 0x18},
+		{0xFFC, 0xFEC, 0x1012},
+		{0x1FF0, 0x1FE0, 0x2000},
+		{0x3004, 0x3000, 0x301A},
+		{0x3FF8, 0x3FE8, 0x4000},
+	}
+
+	for _, chunkSize := range []int{0x10, 0x800, 0x1000, 0} {
+		t.Run(fmt.Sprintf("chunk %d", chunkSize), func(t *testing.T) {
+			var matches []Match
+			err := scanner.Scan(context.Background(), ScanOptions{
+				Pattern:       StringToPattern("WeChat", 0),
+				MaxAddress:    0x7FFFFFFFFFFF,
+				ChunkSize:     chunkSize,
+				ContextBefore: 16,
+				ContextAfter:  16,
+				Handler: func(match Match) bool {
+					matches = append(matches, match)
+					return true
+				},
+			})
+			if err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+			if len(matches) != len(tests) {
+				t.Fatalf("Scan found %d matches, want %d", len(matches), len(tests))
+			}
+
+			for i, tt := range tests {
+				match := matches[i]
+				if match.Address != fakeRegion.BaseAddress+Address(tt.offset) {
+					t.Errorf("match %d Address = %s, want %s", i, match.Address, fakeRegion.BaseAddress+Address(tt.offset))
+					continue
+				}
+				if want := data[tt.beforeStart:tt.offset]; string(match.Before) != string(want) {
+					t.Errorf("match at %s Before = %q, want %q", match.Address, match.Before, want)
+				}
+				if want := data[tt.offset+6 : tt.afterEnd]; string(match.After) != string(want) {
+					t.Errorf("match at %s After = %q, want %q", match.Address, match.After, want)
+				}
+				if match.Region != fakeRegion.region() {
+					t.Errorf("match at %s Region = %+v, want %+v", match.Address, match.Region, fakeRegion.region())
+				}
+			}
+		})
+	}
+}
+
+func TestTerminatorExtend(t *testing.T) {
+	tests := []struct {
+		name       string
+		terminator Terminator
+		data       string
+		start, end int
+		want       int
+	}{
+		{"none", TerminatorNone, "abc\x00def", 0, 2, 7},
+		{"nul", TerminatorNUL, "abc\x00def", 0, 2, 3},
+		{"nul inside pattern", TerminatorNUL, "a\x00bcdefg", 0, 2, 8},
+		{"nul missing", TerminatorNUL, "abcdefgh", 0, 2, 8},
+		{"utf16", TerminatorUTF16NUL, "a\x00b\x00c\x00\x00\x00", 0, 2, 6},
+		// 只在与匹配起点对齐的位置识别 UTF-16 NUL
+		{"utf16 unaligned", TerminatorUTF16NUL, "a\x00b\x00c\x00\x00d", 0, 2, 8},
+		{"utf16 odd pattern", TerminatorUTF16NUL, "abc\x00\x00\x00de", 0, 3, 4},
+		{"printable", TerminatorNonPrintable, "ab c\td\n\x01e", 0, 2, 7},
+		{"printable utf8", TerminatorNonPrintable, "ab微信\x7f", 0, 2, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte(tt.data)
+			limit := min(len(data), 8)
+			if got := tt.terminator.extend(data, tt.start, tt.end, limit); got != tt.want {
+				t.Errorf("extend(%q) = %d, want %d", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScannerMatchExtension(t *testing.T) {
+	source := NewFakeSource(FakeRegion{
+		BaseAddress: 0x10000,
+		Data: makeRegionData(0x1000, map[int]string{
+			0x100: "WeChat hello\x00world",
+			0x7FC: "WeChat\x01",
+			// 区域末尾的匹配不能因为扩展而被丢弃
+			0xFFA: "WeChat",
+		}),
+		Protection: ProtectRead | ProtectWrite,
+	})
+	scanner := NewScannerFromSource(source)
+	defer scanner.Close()
+
+	tests := []struct {
+		name       string
+		terminator Terminator
+		maxLength  int
+		want       []string
+	}{
+		{"pattern only", TerminatorNone, 0, []string{"WeChat", "WeChat", "WeChat"}},
+		{"fixed length", TerminatorNone, 16, []string{"WeChat hello\x00wor", "WeChat\x01" + string(makeRegionData(0x1000, nil)[0x803:0x80C]), "WeChat"}},
+		{"nul", TerminatorNUL, 64, []string{"WeChat hello", "WeChat\x01" + string(makeRegionData(0x1000, nil)[0x803:0x83C]), "WeChat"}},
+		{"non-printable", TerminatorNonPrintable, 0, []string{"WeChat hello", "WeChat", "WeChat"}},
+	}
+
+	for _, tt := range tests {
+		for _, chunkSize := range []int{0x100, 0} {
+			t.Run(fmt.Sprintf("%s chunk %d", tt.name, chunkSize), func(t *testing.T) {
+				var result []string
+				err := scanner.Scan(context.Background(), ScanOptions{
+					Pattern:        StringToPattern("WeChat", 0),
+					MaxAddress:     0x7FFFFFFFFFFF,
+					ChunkSize:      chunkSize,
+					Terminator:     tt.terminator,
+					MaxMatchLength: tt.maxLength,
+					Handler: func(match Match) bool {
+						result = append(result, string(match.Data))
+						return true
+					},
+				})
+				if err != nil {
+					t.Fatalf("Scan failed: %v", err)
+				}
+				if !slices.Equal(result, tt.want) {
+					t.Errorf("match data = %q, want %q", result, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestScannerMultiPattern(t *testing.T) {
+	source := NewFakeSource(FakeRegion{
+		BaseAddress: 0x10000,
+		Data: makeRegionData(0x1000, map[int]string{
+			0x100: "WeChatAppEx",
+			0x200: "wechat",
+			// 跨越块边界的匹配
+			0x7FC: "Tencent",
+			0x900: "QQMusic",
+		}),
+		Protection: ProtectRead | ProtectWrite,
+	})
+	scanner := NewScannerFromSource(source)
+	defer scanner.Close()
+
+	patterns := []NamedPattern{
+		{Name: "wechat", Pattern: StringToPattern("WeChat", 0)},
+		{Name: "app", Pattern: StringToPattern("WeChat?pp", 0)},
+		{Name: "tencent", Pattern: StringToPattern("Tenc?nt", 0)},
+		{Name: "music", Pattern: StringToPattern("Music", 0)},
+	}
+
+	type result struct {
+		address  Address
+		data     string
+		patterns string
+	}
+	tests := []struct {
+		name       string
+		ignoreCase bool
+		want       []result
+	}{
+		{"exact", false, []result{
+			{0x10100, "WeChatApp", "wechat,app"},
+			{0x107FC, "Tencent", "tencent"},
+			{0x10902, "Music", "music"},
+		}},
+		{"ignore case", true, []result{
+			{0x10100, "WeChatApp", "wechat,app"},
+			{0x10200, "wechat", "wechat"},
+			{0x107FC, "Tencent", "tencent"},
+			{0x10902, "Music", "music"},
+		}},
+	}
+
+	for _, tt := range tests {
+		for _, workers := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s workers %d", tt.name, workers), func(t *testing.T) {
+				var results []result
+				err := scanner.Scan(context.Background(), ScanOptions{
+					Patterns:   patterns,
+					IgnoreCase: tt.ignoreCase,
+					MaxAddress: 0x7FFFFFFFFFFF,
+					ChunkSize:  0x800,
+					Workers:    workers,
+					Ordered:    true,
+					Handler: func(match Match) bool {
+						results = append(results, result{match.Address, string(match.Data), strings.Join(match.Patterns, ",")})
+						return true
+					},
+				})
+				if err != nil {
+					t.Fatalf("Scan failed: %v", err)
+				}
+				if !slices.Equal(results, tt.want) {
+					t.Errorf("matches = %v, want %v", results, tt.want)
+				}
+			})
+		}
+	}
+
+	// 无效模式和同时设置 Pattern 与 Patterns 都会报错
+	err := scanner.Scan(context.Background(), ScanOptions{
+		Patterns: []NamedPattern{{Name: "bad", Pattern: "57 XX"}},
+		Handler:  func(Match) bool { return true },
+	})
+	if !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("Scan with invalid pattern error = %v, want ErrInvalidPattern", err)
+	}
+	err = scanner.Scan(context.Background(), ScanOptions{
+		Pattern:  "57",
+		Patterns: patterns,
+		Handler:  func(Match) bool { return true },
+	})
+	if err == nil {
+		t.Error("Scan with Pattern and Patterns succeeded, want error")
+	}
+}
+
+func TestScannerVariableMatch(t *testing.T) {
+	source := NewFakeSource(FakeRegion{
+		BaseAddress: 0x10000,
+		Data: makeRegionData(0x1000, map[int]string{
+			0x100: "Wehat",
+			// 跨越块边界的匹配
+			0x7FE: "We--hat",
+			0xFF9: "WE1hat",
+		}),
+		Protection: ProtectRead | ProtectWrite,
+	})
+	scanner := NewScannerFromSource(source)
+	defer scanner.Close()
+
+	for _, chunkSize := range []int{0x100, 0} {
+		var result []string
+		err := scanner.Scan(context.Background(), ScanOptions{
+			Pattern:    "57 (65|45) [0-4] 68 61 74",
+			MaxAddress: 0x7FFFFFFFFFFF,
+			ChunkSize:  chunkSize,
+			Handler: func(match Match) bool {
+				result = append(result, fmt.Sprintf("%s %s", match.Address, match.Data))
+				return true
+			},
+		})
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		want := []string{"0x10100 Wehat", "0x107FE We--hat", "0x10FF9 WE1hat"}
+		if !slices.Equal(result, want) {
+			t.Errorf("chunk %d: matches = %q, want %q", chunkSize, result, want)
+		}
+	}
+}
+
+func TestScannerRegexp(t *testing.T) {
+	source := NewFakeSource(FakeRegion{
+		BaseAddress: 0x10000,
+		Data: makeRegionData(0x1000, map[int]string{
+			0x100: "mail=alice@example.com;",
+			// 跨越块边界的匹配
+			0x7F0: "key=0123456789ABCDEF0123456789abcdef;",
+			0x900: "\x00" + strings.Repeat("a", 300) + "\x00",
+			// 以下内容位于 0x100 大小的块的边界上
+			0x200: "foo",
+			0x2FF: " bar",
+			0x3FF: "\nbaz",
+			0x4FD: "qux",
+			// 跨越块边界的多字节字符
+			0x5FF: "中QR",
+			0x6FE: "中ST",
+		}),
+		Protection: ProtectRead | ProtectWrite,
+	})
+	scanner := NewScannerFromSource(source)
+	defer scanner.Close()
+
+	tests := []struct {
+		name       string
+		regexp     string
+		ignoreCase bool
+		want       []string
+	}{
+		{"captures", `([a-z]+)@([a-z]+)\.com`, false, []string{"0x10105 alice@example.com [alice example]"}},
+		{"chunk boundary", `key=([0-9a-f]{32})`, true, []string{"0x107F0 key=0123456789ABCDEF0123456789abcdef [0123456789ABCDEF0123456789abcdef]"}},
+		{"case sensitive", `key=([0-9a-f]{32})`, false, nil},
+		{"optional group", `(mail|key)=(x)?`, false, []string{"0x10100 mail= [mail <nil>]", "0x107F0 key= [key <nil>]"}},
+		// 长匹配只由它开始的块报告一次
+		{"long match", `a{10,}`, false, []string{"0x10901 " + strings.Repeat("a", 300) + " []"}},
+		// 断言看到块边界前后的字节，结果与块大小无关
+		{"word boundary", `\bfoo`, false, nil},
+		{"no word boundary", `\Bfoo`, false, []string{"0x10200 foo []"}},
+		{"word boundary after space", `\bbar`, false, []string{"0x10300 bar []"}},
+		{"text start", `^foo|\Abar`, false, nil},
+		{"line start", `(?m)^baz`, false, []string{"0x10400 baz []"}},
+		{"word boundary at end", `qux\b`, false, nil},
+		{"no word boundary at end", `qux\B`, false, []string{"0x104FD qux []"}},
+		// 匹配不从跨越块边界的字符中间开始
+		{"character across boundary", `[Q-T]{2}`, false, []string{"0x10602 QR []", "0x10701 ST []"}},
+		{"whole character", `\p{Han}(QR|ST)`, false, []string{"0x105FF 中QR [QR]", "0x106FE 中ST [ST]"}},
+		{"any character", `.(QR|ST)`, false, []string{"0x105FF 中QR [QR]", "0x106FE 中ST [ST]"}},
+		{"inside character", `[^\p{Han}](QR|ST)`, false, nil},
+	}
+
+	for _, tt := range tests {
+		for _, chunkSize := range []int{0x100, 0x1000, 0} {
+			t.Run(fmt.Sprintf("%s chunk %d", tt.name, chunkSize), func(t *testing.T) {
+				var result []string
+				err := scanner.Scan(context.Background(), ScanOptions{
+					Regexp:     tt.regexp,
+					IgnoreCase: tt.ignoreCase,
+					MaxAddress: 0x7FFFFFFFFFFF,
+					ChunkSize:  chunkSize,
+					Handler: func(match Match) bool {
+						captures := make([]string, len(match.Captures))
+						for i, capture := range match.Captures {
+							captures[i] = "<nil>"
+							if capture != nil {
+								captures[i] = string(capture)
+							}
+						}
+						result = append(result, fmt.Sprintf("%s %s %v", match.Address, match.Data, captures))
+						return true
+					},
+				})
+				if err != nil {
+					t.Fatalf("Scan failed: %v", err)
+				}
+				if !slices.Equal(result, tt.want) {
+					t.Errorf("matches = %q, want %q", result, tt.want)
+				}
+			})
+		}
+	}
+
+	// 无效的正则表达式指出出错的部分，不能与 Pattern 同时使用
+	err := scanner.Scan(context.Background(), ScanOptions{Regexp: `key=(\d+`, Handler: func(Match) bool { return true }})
+	var patternErr *PatternError
+	if !errors.As(err, &patternErr) || !errors.Is(err, ErrInvalidPattern) || patternErr.Token != `key=(\d+` {
+		t.Errorf("Scan with invalid regexp error = %#v, want a PatternError", err)
+	}
+	err = scanner.Scan(context.Background(), ScanOptions{Regexp: `key=a**`, Handler: func(Match) bool { return true }})
+	if !errors.As(err, &patternErr) || patternErr.Pos != 5 || patternErr.Token != "**" {
+		t.Errorf("Scan with invalid regexp error = %#v, want position 5 token \"**\"", err)
+	}
+	err = scanner.Scan(context.Background(), ScanOptions{Regexp: "a", Pattern: "61", Handler: func(Match) bool { return true }})
+	if err == nil {
+		t.Error("Scan with Regexp and Pattern succeeded, want error")
+	}
+}
+
+func TestFakeSourceReadAt(t *testing.T) {
+	source := NewFakeSource(
+		FakeRegion{
+			BaseAddress: 0x1000,
+			Data:        makeRegionData(0x1000, nil),
+			Protection:  ProtectRead,
+			Unreadable:  []AddressRange{{Start: 0x1800, End: 0x1900}},
+		},
+		FakeRegion{
+			BaseAddress: 0x2000,
+			Data:        makeRegionData(0x1000, nil),
+			Protection:  ProtectRead,
+		},
+	)
+
+	tests := []struct {
+		name    string
+		address Address
+		length  int
+		read    int
+		wantErr bool
+	}{
+		{name: "inside region", address: 0x1000, length: 0x100, read: 0x100},
+		{name: "stops at hole", address: 0x1700, length: 0x200, read: 0x100, wantErr: true},
+		{name: "starts in hole", address: 0x1850, length: 0x10, read: 0, wantErr: true},
+		{name: "crosses adjacent regions", address: 0x1F00, length: 0x200, read: 0x200},
+		{name: "runs past mapped memory", address: 0x2F00, length: 0x200, read: 0x100, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := make([]byte, tt.length)
+			n, err := source.ReadAt(buffer, tt.address)
+			if n != tt.read || (err != nil) != tt.wantErr {
+				t.Errorf("ReadAt(%s) = %d, %v; want %d, error %v", tt.address, n, err, tt.read, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRawSource(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"0x10000.bin":       makeRegionData(0x1000, map[int]string{0x40: "WeChat"}),
+		"7ff60000-7ff61000": makeRegionData(0x1000, map[int]string{0xFFA: "WeChat"}),
+		"notes.txt":         []byte("WeChat"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	// 目录模式：按文件名中的基址映射，忽略其他文件
+	source, err := OpenRawDir(dir)
+	if err != nil {
+		t.Fatalf("OpenRawDir failed: %v", err)
+	}
+	scanner := NewScannerFromSource(source)
+	defer scanner.Close()
+
+	result := scanAddresses(t, scanner, ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		MaxAddress: 0x7FFFFFFFFFFF,
+	})
+	if want := []Address{0x10040, 0x7FF60FFA}; !slices.Equal(result, want) {
+		t.Errorf("Scan addresses = %v, want %v", result, want)
+	}
+
+	// 单文件模式：调用者指定基址
+	single, err := OpenRawFiles(RawFile{Path: filepath.Join(dir, "notes.txt"), BaseAddress: 0x5000})
+	if err != nil {
+		t.Fatalf("OpenRawFiles failed: %v", err)
+	}
+	singleScanner := NewScannerFromSource(single)
+	defer singleScanner.Close()
+
+	result = scanAddresses(t, singleScanner, ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		MaxAddress: 0x7FFFFFFFFFFF,
+	})
+	if want := []Address{0x5000}; !slices.Equal(result, want) {
+		t.Errorf("Scan addresses = %v, want %v", result, want)
+	}
+
+	// 重叠的文件应报错
+	_, err = OpenRawFiles(
+		RawFile{Path: filepath.Join(dir, "0x10000.bin"), BaseAddress: 0x10000},
+		RawFile{Path: filepath.Join(dir, "notes.txt"), BaseAddress: 0x10FFF},
+	)
+	if err == nil {
+		t.Error("OpenRawFiles with overlapping files succeeded, want error")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	oldData := makeRegionData(0x1000, map[int]string{0x10: "score=100"})
+	newData := makeRegionData(0x2000, map[int]string{0x10: "score=250", 0xFFF: "X"})
+
+	oldSource := NewFakeSource(
+		FakeRegion{BaseAddress: 0x10000, Data: oldData, Protection: ProtectRead | ProtectWrite},
+		FakeRegion{BaseAddress: 0x20000, Data: makeRegionData(0x1000, nil), Protection: ProtectRead},
+	)
+	newSource := NewFakeSource(
+		// 区域增长到 0x2000 字节
+		FakeRegion{BaseAddress: 0x10000, Data: newData, Protection: ProtectRead | ProtectWrite},
+		FakeRegion{BaseAddress: 0x30000, Data: makeRegionData(0x1000, nil), Protection: ProtectRead},
+	)
+
+	result, err := Diff(context.Background(), oldSource, newSource, DiffOptions{MaxAddress: 0x7FFFFFFFFFFF})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	expectedChanges := []struct {
+		address Address
+		old     string
+		new     string
+	}{
+		{address: 0x10016, old: "10", new: "25"},
+		{address: 0x10FFF, old: string(oldData[0xFFF:]), new: "X"},
+	}
+	if len(result.Changes) != len(expectedChanges) {
+		t.Fatalf("Expected %d changes, got %d: %+v", len(expectedChanges), len(result.Changes), result.Changes)
+	}
+	for i, want := range expectedChanges {
+		change := result.Changes[i]
+		if change.Address != want.address || string(change.Old) != want.old || string(change.New) != want.new {
+			t.Errorf("Change[%d] = %s %q -> %q, want %s %q -> %q",
+				i, change.Address, change.Old, change.New, want.address, want.old, want.new)
+		}
+	}
+
+	regionRanges := func(regions []Region) []AddressRange {
+		var ranges []AddressRange
+		for _, region := range regions {
+			ranges = append(ranges, AddressRange{Start: region.BaseAddress, End: region.End()})
+		}
+		return ranges
+	}
+	if added, want := regionRanges(result.Added), []AddressRange{{Start: 0x11000, End: 0x12000}, {Start: 0x30000, End: 0x31000}}; !slices.Equal(added, want) {
+		t.Errorf("Added = %v, want %v", added, want)
+	}
+	if removed, want := regionRanges(result.Removed), []AddressRange{{Start: 0x20000, End: 0x21000}}; !slices.Equal(removed, want) {
+		t.Errorf("Removed = %v, want %v", removed, want)
+	}
+
+	// 地址范围限制同样适用于比较
+	result, err = Diff(context.Background(), oldSource, newSource, DiffOptions{MinAddress: 0x10100, MaxAddress: 0x20000})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Address != 0x10FFF {
+		t.Errorf("Changes = %+v, want only the change at 0x10FFF", result.Changes)
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Address
+		wantErr  bool
+	}{
+		{input: "0x7FF6A000", expected: 0x7FF6A000},
+		{input: "7ff6a000", expected: 0x7FF6A000},
+		{input: " 0X1234 ", expected: 0x1234},
+		{input: "0x", wantErr: true},
+		{input: "WeChat", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseAddress(tt.input)
+			if (err != nil) != tt.wantErr || result != tt.expected {
+				t.Errorf("ParseAddress(%q) = %s, %v; want %s, error %v", tt.input, result, err, tt.expected, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddressString(t *testing.T) {
+	tests := []struct {
+		input    Address
+		expected string
+	}{
+		{0x0, "0x0"},
+		{0x1234, "0x1234"},
+		{0x7FFFFFFFFFFF, "0x7FFFFFFFFFFF"},
+	}
+
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			result := tt.input.String()
+			if result != tt.expected {
+				t.Errorf("Address(%d).String() = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRegionReadable(t *testing.T) {
+	tests := []struct {
+		name     string
+		region   Region
+		expected bool
+		protect  string
+	}{
+		{
+			name:     "committed read-write",
+			region:   Region{Protection: ProtectRead | ProtectWrite, State: StateCommit},
+			expected: true,
+			protect:  "rw-",
+		},
+		{
+			name:     "reserved",
+			region:   Region{Protection: ProtectRead, State: StateReserve},
+			expected: false,
+			protect:  "r--",
+		},
+		{
+			name:     "guard page",
+			region:   Region{Protection: ProtectRead | ProtectWrite | ProtectGuard},
+			expected: false,
+			protect:  "rw-+guard",
+		},
+		{
+			name:     "execute only",
+			region:   Region{Protection: ProtectExecute},
+			expected: false,
+			protect:  "--x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.region.Readable(); result != tt.expected {
+				t.Errorf("Region.Readable() = %v, want %v", result, tt.expected)
+			}
+			if result := tt.region.Protection.String(); result != tt.protect {
+				t.Errorf("Protection.String() = %q, want %q", result, tt.protect)
+			}
+		})
+	}
+}
+
+func TestMatchContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{
+			name:     "valid UTF-8",
+			data:     []byte("Hello World"),
+			expected: "Hello World",
+		},
+		{
+			name:     "empty data",
+			data:     []byte{},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := Match{Data: tt.data}
+			result := match.Content()
+			if result != tt.expected {
+				t.Errorf("Match.Content() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// 辅助函数：截断字符串用于测试显示
+func truncateStringForTest(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	if maxLen <= 3 {
+		return s[:maxLen]
+	}
+	return s[:maxLen-3] + "..."
+}
+
+// 基准测试：模式匹配性能
+func BenchmarkPatternMatcher(b *testing.B) {
+	pattern := "57 65 43 68 61 74"
+	matcher, err := NewPatternMatcher(pattern)
+	if err != nil {
+		b.Fatalf("NewPatternMatcher failed: %v", err)
+	}
+
+	// 创建测试数据 (1KB)
+	data := make([]byte, 1024)
+	for i := range data {
+		data[i] = byte(i % 256)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matcher.FindMatches(data, false)
+	}
+}
+
+// 朴素的逐位置匹配，用作快速搜索的参照
+func naiveFindMatches(pm *PatternMatcher, data []byte, ignoreCase bool) []int {
+	var matches []int
+	for i := 0; i+len(pm.patternBytes) <= len(data); i++ {
+		matched := true
+		for j := 0; j < len(pm.patternBytes) && matched; j++ {
+			a, b := data[i+j]&pm.masks[j], pm.patternBytes[j]
+			if ignoreCase && pm.masks[j] == 0xFF {
+				a, b = foldTable[a], foldTable[b]
+			}
+			matched = a == b
+		}
+		if matched {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+func TestFindMatchesRandom(t *testing.T) {
+	// 随机模式和数据下，快速搜索与朴素匹配结果一致
+	rng := rand.New(rand.NewPCG(1, 2))
+	alphabet := []byte("aAbB\x00\xff")
+	data := make([]byte, 4096)
+	for i := range data {
+		data[i] = alphabet[rng.IntN(len(alphabet))]
+	}
+
+	for i := 0; i < 500; i++ {
+		pattern := randomPattern(rng, alphabet)
+		matcher, err := NewPatternMatcher(pattern)
+		if err != nil {
+			t.Fatalf("NewPatternMatcher(%q) failed: %v", pattern, err)
+		}
+
+		for _, ignoreCase := range []bool{false, true} {
+			got := matcher.FindMatches(data, ignoreCase)
+			want := naiveFindMatches(matcher, data, ignoreCase)
+			if !slices.Equal(got, want) {
+				t.Fatalf("FindMatches(%q, ignoreCase=%v) found %d matches, want %d", pattern, ignoreCase, len(got), len(want))
+			}
+		}
+	}
+}
+
+// 辅助函数：生成由 alphabet 中的字节组成的随机 AOB 模式
+func randomPattern(rng *rand.Rand, alphabet []byte) string {
+	var parts []string
+	for j := rng.IntN(6) + 1; j > 0; j-- {
+		b := alphabet[rng.IntN(len(alphabet))]
+		switch rng.IntN(8) {
+		case 0, 1:
+			parts = append(parts, "??")
+		case 2:
+			parts = append(parts, fmt.Sprintf("%X?", b>>4))
+		case 3:
+			parts = append(parts, fmt.Sprintf("%02X&%02X", b, byte(rng.Uint32())))
+		default:
+			parts = append(parts, fmt.Sprintf("%02X", b))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// 朴素回溯匹配的后续元素，用作参照
+type naiveContinuation struct {
+	elements []patternElement
+	next     *naiveContinuation
+}
+
+// 朴素的回溯匹配：按顺序尝试分支，跳转从最短开始，用作记忆化匹配的参照
+func naiveMatchSequence(elements []patternElement, next *naiveContinuation, data []byte, pos int,
+	ignoreCase bool) (int, bool) {
+
+	for i, e := range elements {
+		switch e.kind {
+		case elementByte:
+			if pos >= len(data) || !matchByte(data[pos], e.value, e.mask, ignoreCase) {
+				return 0, false
+			}
+			pos++
+		case elementJump:
+			for n := e.min; n <= e.max && pos+n <= len(data); n++ {
+				if end, ok := naiveMatchSequence(elements[i+1:], next, data, pos+n, ignoreCase); ok {
+					return end, true
+				}
+			}
+			return 0, false
+		case elementGroup:
+			rest := &naiveContinuation{elements: elements[i+1:], next: next}
+			for _, alternative := range e.alternatives {
+				if end, ok := naiveMatchSequence(alternative, rest, data, pos, ignoreCase); ok {
+					return end, true
+				}
+			}
+			return 0, false
+		}
+	}
+
+	if pos > len(data) {
+		return 0, false
+	}
+	if next != nil {
+		return naiveMatchSequence(next.elements, next.next, data, pos, ignoreCase)
+	}
+	return pos, true
+}
+
+func TestFindSpansRandom(t *testing.T) {
+	// 含跳转和分支的随机模式，锚点搜索与朴素回溯逐位置匹配的结果一致
+	rng := rand.New(rand.NewPCG(7, 8))
+	alphabet := []byte("aAbB\x00\xff")
+	data := make([]byte, 4096)
+	for i := range data {
+		data[i] = alphabet[rng.IntN(len(alphabet))]
+	}
+
+	for i := 0; i < 300; i++ {
+		parts := []string{randomPattern(rng, alphabet)}
+		for j := rng.IntN(3); j > 0; j-- {
+			if rng.IntN(2) == 0 {
+				low := rng.IntN(3)
+				parts = append(parts, fmt.Sprintf("[%d-%d]", low, low+rng.IntN(4)))
+			} else {
+				// 分支中也可以有跳转
+				alternative := randomPattern(rng, alphabet)
+				if rng.IntN(2) == 0 {
+					alternative += fmt.Sprintf(" [0-%d] ", rng.IntN(3)+1) + randomPattern(rng, alphabet)
+				}
+				parts = append(parts, "("+alternative+"|"+randomPattern(rng, alphabet)+")")
+			}
+			parts = append(parts, randomPattern(rng, alphabet))
+		}
+		pattern := strings.Join(parts, " ")
+		matcher, err := NewPatternMatcher(pattern)
+		if err != nil {
+			t.Fatalf("NewPatternMatcher(%q) failed: %v", pattern, err)
+		}
+		parser := patternParser{pattern: pattern, tokens: splitPattern(pattern)}
+		elements, _ := parser.parseSequence()
+
+		for _, ignoreCase := range []bool{false, true} {
+			var want []MatchSpan
+			for start := 0; start+matcher.GetMinPatternLength() <= len(data); start++ {
+				if end, ok := naiveMatchSequence(elements, nil, data, start, ignoreCase); ok {
+					want = append(want, MatchSpan{Start: start, End: end})
+				}
+			}
+			if got := matcher.FindSpans(data, ignoreCase); !slices.Equal(got, want) {
+				t.Fatalf("FindSpans(%q, ignoreCase=%v) found %d matches, want %d", pattern, ignoreCase, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestMultiMatcherRandom(t *testing.T) {
+	// 多模式自动机的结果与逐个模式朴素匹配的结果一致
+	rng := rand.New(rand.NewPCG(5, 6))
+	alphabet := []byte("aAbB\x00\xff")
+	data := make([]byte, 4096)
+	for i := range data {
+		data[i] = alphabet[rng.IntN(len(alphabet))]
+	}
+
+	for i := 0; i < 100; i++ {
+		patterns := make([]NamedPattern, rng.IntN(8)+1)
+		for j := range patterns {
+			patterns[j] = NamedPattern{Name: strconv.Itoa(j), Pattern: randomPattern(rng, alphabet)}
+		}
+
+		for _, ignoreCase := range []bool{false, true} {
+			matcher, err := newMultiMatcher(patterns, ignoreCase)
+			if err != nil {
+				t.Fatalf("newMultiMatcher failed: %v", err)
+			}
+
+			// 按位置汇总每个模式的朴素匹配结果
+			want := map[int][]string{}
+			for j, pattern := range patterns {
+				for _, offset := range naiveFindMatches(matcher.matchers[j], data, ignoreCase) {
+					want[offset] = append(want[offset], pattern.Name)
+				}
+			}
+
+			got := matcher.find(data, 0)
+			if len(got) != len(want) {
+				t.Fatalf("find(%v, ignoreCase=%v) found %d positions, want %d", patterns, ignoreCase, len(got), len(want))
+			}
+			for _, match := range got {
+				if !slices.Equal(match.patterns, want[match.offset]) {
+					t.Fatalf("find(%v, ignoreCase=%v) at %d = %v, want %v", patterns, ignoreCase, match.offset, match.patterns, want[match.offset])
+				}
+			}
+		}
+	}
+}
+
+var (
+	benchmarkMemoryOnce sync.Once
+	benchmarkMemoryData []byte
+)
+
+// 辅助函数：生成 16MB 类似进程内存的数据（文本、零页和随机二进制混合），少量位置含有目标字符串
+func benchmarkMemory() []byte {
+	benchmarkMemoryOnce.Do(func() {
+		rng := rand.New(rand.NewPCG(3, 4))
+		words := []string{"wechat ", "message ", "http://", "<div>", "{\"id\":", "WeChat", "chat ", "room "}
+		data := make([]byte, 0, 16<<20)
+		for len(data) < 16<<20 {
+			switch rng.IntN(3) {
+			case 0:
+				for i := 0; i < 512; i++ {
+					data = append(data, words[rng.IntN(len(words))]...)
+				}
+			case 1:
+				data = append(data, make([]byte, 4096)...)
+			default:
+				for i := 0; i < 4096; i++ {
+					data = append(data, byte(rng.Uint32()))
+				}
+			}
+			if rng.IntN(64) == 0 {
+				data = append(data, "WeChatAppEx"...)
+			}
+		}
+		benchmarkMemoryData = data[:16<<20]
+	})
+	return benchmarkMemoryData
+}
+
+func BenchmarkFindMatches(b *testing.B) {
+	benchmarks := []struct {
+		name       string
+		pattern    string
+		ignoreCase bool
+	}{
+		{"exact", StringToPattern("WeChatAppEx", 0), false},
+		{"ignore case", StringToPattern("WeChatAppEx", 0), true},
+		{"wildcards", StringToPattern("We?hat?ppEx", 0), false},
+		{"short", "41 70", false},
+	}
+
+	data := benchmarkMemory()
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			matcher, err := NewPatternMatcher(bm.pattern)
+			if err != nil {
+				b.Fatalf("NewPatternMatcher failed: %v", err)
+			}
+
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				matcher.FindMatches(data, bm.ignoreCase)
+			}
+		})
+	}
+}
+
+func BenchmarkMultiMatcher(b *testing.B) {
+	// 一次扫描同时搜索 50 个字符串
+	patterns := make([]NamedPattern, 50)
+	for i := range patterns {
+		patterns[i] = NamedPattern{Name: strconv.Itoa(i), Pattern: StringToPattern(fmt.Sprintf("WeChat%02dAppEx", i), 0)}
+	}
+	matcher, err := newMultiMatcher(patterns, true)
+	if err != nil {
+		b.Fatalf("newMultiMatcher failed: %v", err)
+	}
+
+	data := benchmarkMemory()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matcher.find(data, 0)
+	}
+}
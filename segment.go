@@ -0,0 +1,93 @@
+package memoryscanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// segment maps a region of the original address space to bytes stored in a file
+type segment struct {
+	region Region
+	// reader holds the region contents starting at offset
+	reader io.ReaderAt
+	offset int64
+	// dataSize is the number of region bytes present in the file, starting at
+	// the region base; the remainder of the region was not captured
+	dataSize uint64
+}
+
+// segmentSource is a MemorySource backed by file segments, shared by the
+// offline sources (core dumps, minidumps, raw files)
+type segmentSource struct {
+	segments []segment
+	closer   io.Closer
+}
+
+// newSegmentSource creates a segment source, sorting the segments by address
+func newSegmentSource(segments []segment, closer io.Closer) *segmentSource {
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].region.BaseAddress < segments[j].region.BaseAddress
+	})
+	return &segmentSource{segments: segments, closer: closer}
+}
+
+// Regions returns the regions of all segments in ascending address order
+func (s *segmentSource) Regions(ctx context.Context) ([]Region, error) {
+	regions := make([]Region, len(s.segments))
+	for i, seg := range s.segments {
+		regions[i] = seg.region
+	}
+	return regions, nil
+}
+
+// ReadAt reads captured bytes starting at addr, crossing into adjacent segments
+func (s *segmentSource) ReadAt(p []byte, addr Address) (int, error) {
+	n := 0
+	for n < len(p) {
+		current := addr + Address(n)
+		seg, ok := s.find(current)
+		if !ok {
+			return n, fmt.Errorf("memory at %s is not mapped", current)
+		}
+
+		offset := uint64(current - seg.region.BaseAddress)
+		if offset >= seg.dataSize {
+			return n, fmt.Errorf("memory at %s was not captured", current)
+		}
+
+		length := min(uint64(len(p)-n), seg.dataSize-offset)
+		read, err := seg.reader.ReadAt(p[n:n+int(length)], seg.offset+int64(offset))
+		n += read
+		if read < int(length) {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, fmt.Errorf("failed to read memory at %s: %w", current+Address(read), err)
+		}
+	}
+
+	return n, nil
+}
+
+// find returns the segment containing addr
+func (s *segmentSource) find(addr Address) (segment, bool) {
+	i := sort.Search(len(s.segments), func(i int) bool {
+		return s.segments[i].region.End() > addr
+	})
+	if i < len(s.segments) && s.segments[i].region.BaseAddress <= addr {
+		return s.segments[i], true
+	}
+	return segment{}, false
+}
+
+// Close closes the underlying file
+func (s *segmentSource) Close() error {
+	if s.closer != nil {
+		err := s.closer.Close()
+		s.closer = nil
+		return err
+	}
+	return nil
+}
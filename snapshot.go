@@ -0,0 +1,419 @@
+package memoryscanner
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Snapshot file layout (all integers little-endian):
+//
+//	header:  magic[8] version:u32 pid:u32 time:i64 regionCount:u32
+//	regions: base:u64 size:u64 protection:u32 state:u32 type:u32 nameLen:u32 name[nameLen]
+//	content: for every readable region in table order, blocks covering the whole region:
+//	         rawLen:u32 compLen:u32 data[compLen]
+//
+// Block data is compressed with DEFLATE. A block with compLen 0 is a range of
+// rawLen bytes that could not be read when the snapshot was taken.
+const (
+	snapshotMagic     = "MEMSNAP\x00"
+	snapshotVersion   = 1
+	snapshotBlockSize = 1 << 20
+	// snapshotMaxNameLen bounds region names, longer names are cut when writing
+	snapshotMaxNameLen = 1 << 16
+)
+
+// SnapshotOptions contains configuration options for writing a snapshot
+type SnapshotOptions struct {
+	// Minimum address to capture from (inclusive)
+	MinAddress Address
+	// Maximum address to capture to (exclusive)
+	MaxAddress Address
+}
+
+// WriteSnapshot captures the regions of the memory source between MinAddress
+// and MaxAddress into w. The contents of readable regions are stored compressed;
+// the snapshot can be scanned any number of times with OpenSnapshot.
+func (s *Scanner) WriteSnapshot(ctx context.Context, w io.Writer, opts SnapshotOptions) error {
+	allRegions, err := s.Regions(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Clip the regions to the requested address range
+	var regions []Region
+	for _, region := range allRegions {
+		start := max(region.BaseAddress, opts.MinAddress)
+		end := min(region.End(), opts.MaxAddress)
+		if end <= start {
+			continue
+		}
+		region.BaseAddress = start
+		region.Size = uint64(end - start)
+		regions = append(regions, region)
+	}
+
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 0, 28)
+	header = append(header, snapshotMagic...)
+	header = binary.LittleEndian.AppendUint32(header, snapshotVersion)
+	header = binary.LittleEndian.AppendUint32(header, s.pid)
+	header = binary.LittleEndian.AppendUint64(header, uint64(time.Now().UnixNano()))
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(regions)))
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+
+	for _, region := range regions {
+		name := region.Name[:min(len(region.Name), snapshotMaxNameLen)]
+		entry := make([]byte, 0, 32+len(name))
+		entry = binary.LittleEndian.AppendUint64(entry, uint64(region.BaseAddress))
+		entry = binary.LittleEndian.AppendUint64(entry, region.Size)
+		entry = binary.LittleEndian.AppendUint32(entry, uint32(region.Protection))
+		entry = binary.LittleEndian.AppendUint32(entry, uint32(region.State))
+		entry = binary.LittleEndian.AppendUint32(entry, uint32(region.Type))
+		entry = binary.LittleEndian.AppendUint32(entry, uint32(len(name)))
+		entry = append(entry, name...)
+		if _, err := bw.Write(entry); err != nil {
+			return err
+		}
+	}
+
+	var compressed bytes.Buffer
+	compressor, err := flate.NewWriter(&compressed, flate.BestSpeed)
+	if err != nil {
+		return err
+	}
+	buffer := make([]byte, snapshotBlockSize)
+
+	writeBlock := func(rawLen int, data []byte) error {
+		compressed.Reset()
+		if data != nil {
+			compressor.Reset(&compressed)
+			if _, err := compressor.Write(data); err != nil {
+				return err
+			}
+			if err := compressor.Close(); err != nil {
+				return err
+			}
+		}
+
+		blockHeader := binary.LittleEndian.AppendUint32(nil, uint32(rawLen))
+		blockHeader = binary.LittleEndian.AppendUint32(blockHeader, uint32(compressed.Len()))
+		if _, err := bw.Write(blockHeader); err != nil {
+			return err
+		}
+		_, err := bw.Write(compressed.Bytes())
+		return err
+	}
+
+	for _, region := range regions {
+		if !region.Readable() {
+			continue
+		}
+
+		for offset := uint64(0); offset < region.Size; {
+			// Check if context was cancelled
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+
+			length := int(min(region.Size-offset, snapshotBlockSize))
+			runs, err := readWithFallback(s.source, buffer[:length], region.BaseAddress+Address(offset))
+			if err != nil {
+				return err
+			}
+
+			// Store readable runs as data blocks and the gaps between them as unreadable blocks
+			position := 0
+			for _, run := range runs {
+				if run.start > position {
+					if err := writeBlock(run.start-position, nil); err != nil {
+						return err
+					}
+				}
+				if err := writeBlock(run.end-run.start, buffer[run.start:run.end]); err != nil {
+					return err
+				}
+				position = run.end
+			}
+			if position < length {
+				if err := writeBlock(length-position, nil); err != nil {
+					return err
+				}
+			}
+			offset += uint64(length)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// snapshotBlock locates one block of a region's contents in the snapshot file
+type snapshotBlock struct {
+	// offset is the position of the block within its region
+	offset uint64
+	rawLen uint64
+	// fileOffset is the position of the compressed data, compLen 0 means unreadable
+	fileOffset int64
+	compLen    int64
+}
+
+// snapshotRegion is a region of a snapshot and the blocks holding its contents
+type snapshotRegion struct {
+	region Region
+	blocks []snapshotBlock
+}
+
+// SnapshotSource is a MemorySource that reads a snapshot written by Scanner.WriteSnapshot
+type SnapshotSource struct {
+	file    *os.File
+	pid     uint32
+	time    time.Time
+	regions []snapshotRegion
+
+	// cache holds the most recently used decompressed blocks, one or more for
+	// each of the goroutines reading at the same time
+	mu    sync.Mutex
+	cache []snapshotCacheEntry
+	clock uint64
+}
+
+// snapshotCacheEntry is a decompressed block in the cache of a SnapshotSource.
+// data is never written once the entry is added, so readers copy from it
+// without holding the lock.
+type snapshotCacheEntry struct {
+	block *snapshotBlock
+	data  []byte
+	// used is the value of the clock when the block was last read
+	used uint64
+}
+
+// OpenSnapshot opens a snapshot file for scanning
+func OpenSnapshot(path string) (*SnapshotSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &SnapshotSource{
+		file:  file,
+		cache: make([]snapshotCacheEntry, 0, max(runtime.GOMAXPROCS(0), 4)),
+	}
+	if err := source.load(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+
+	return source, nil
+}
+
+// load parses the header and region table and indexes the content blocks
+func (s *SnapshotSource) load() error {
+	reader := bufio.NewReader(io.NewSectionReader(s.file, 0, 1<<62))
+	var offset int64
+	read := func(p []byte) error {
+		n, err := io.ReadFull(reader, p)
+		offset += int64(n)
+		return err
+	}
+
+	header := make([]byte, 28)
+	if err := read(header); err != nil {
+		return err
+	}
+	if string(header[:8]) != snapshotMagic {
+		return errors.New("bad magic")
+	}
+	if version := binary.LittleEndian.Uint32(header[8:]); version != snapshotVersion {
+		return fmt.Errorf("unsupported version %d", version)
+	}
+	s.pid = binary.LittleEndian.Uint32(header[12:])
+	s.time = time.Unix(0, int64(binary.LittleEndian.Uint64(header[16:])))
+	count := binary.LittleEndian.Uint32(header[24:])
+
+	entry := make([]byte, 32)
+	for i := uint32(0); i < count; i++ {
+		if err := read(entry); err != nil {
+			return err
+		}
+		nameLen := binary.LittleEndian.Uint32(entry[28:])
+		if nameLen > snapshotMaxNameLen {
+			return fmt.Errorf("region name of %d bytes is too long", nameLen)
+		}
+		name := make([]byte, nameLen)
+		if err := read(name); err != nil {
+			return err
+		}
+
+		region := Region{
+			BaseAddress: Address(binary.LittleEndian.Uint64(entry[0:])),
+			Size:        binary.LittleEndian.Uint64(entry[8:]),
+			Protection:  Protection(binary.LittleEndian.Uint32(entry[16:])),
+			State:       RegionState(binary.LittleEndian.Uint32(entry[20:])),
+			Type:        RegionType(binary.LittleEndian.Uint32(entry[24:])),
+			Name:        string(name),
+		}
+		if len(s.regions) > 0 && region.BaseAddress < s.regions[len(s.regions)-1].region.End() {
+			return errors.New("regions out of order")
+		}
+		s.regions = append(s.regions, snapshotRegion{region: region})
+	}
+
+	blockHeader := make([]byte, 8)
+	for i := range s.regions {
+		r := &s.regions[i]
+		if !r.region.Readable() {
+			continue
+		}
+
+		for covered := uint64(0); covered < r.region.Size; {
+			if err := read(blockHeader); err != nil {
+				return err
+			}
+			block := snapshotBlock{
+				offset:     covered,
+				rawLen:     uint64(binary.LittleEndian.Uint32(blockHeader[0:])),
+				fileOffset: offset,
+				compLen:    int64(binary.LittleEndian.Uint32(blockHeader[4:])),
+			}
+			if block.rawLen == 0 || block.rawLen > snapshotBlockSize || covered+block.rawLen > r.region.Size {
+				return errors.New("corrupt block table")
+			}
+			if _, err := reader.Discard(int(block.compLen)); err != nil {
+				return err
+			}
+			offset += block.compLen
+
+			r.blocks = append(r.blocks, block)
+			covered += block.rawLen
+		}
+	}
+
+	return nil
+}
+
+// PID returns the process ID the snapshot was taken from, 0 if it was not a live process
+func (s *SnapshotSource) PID() uint32 {
+	return s.pid
+}
+
+// Time returns when the snapshot was taken
+func (s *SnapshotSource) Time() time.Time {
+	return s.time
+}
+
+// Regions returns the region table of the snapshot
+func (s *SnapshotSource) Regions(ctx context.Context) ([]Region, error) {
+	regions := make([]Region, len(s.regions))
+	for i, r := range s.regions {
+		regions[i] = r.region
+	}
+	return regions, nil
+}
+
+// ReadAt reads snapshot contents starting at addr, crossing into adjacent regions.
+// It is safe for concurrent use; blocks are decompressed without holding a lock.
+func (s *SnapshotSource) ReadAt(p []byte, addr Address) (int, error) {
+	n := 0
+	for n < len(p) {
+		current := addr + Address(n)
+		i := sort.Search(len(s.regions), func(i int) bool {
+			return s.regions[i].region.End() > current
+		})
+		if i == len(s.regions) || s.regions[i].region.BaseAddress > current {
+			return n, fmt.Errorf("memory at %s is not mapped", current)
+		}
+
+		r := &s.regions[i]
+		offset := uint64(current - r.region.BaseAddress)
+		j := sort.Search(len(r.blocks), func(j int) bool {
+			return r.blocks[j].offset+r.blocks[j].rawLen > offset
+		})
+		if j == len(r.blocks) || r.blocks[j].compLen == 0 {
+			return n, fmt.Errorf("memory at %s was not captured", current)
+		}
+
+		data, err := s.decompress(&r.blocks[j])
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], data[offset-r.blocks[j].offset:])
+	}
+
+	return n, nil
+}
+
+// decompress returns the contents of a block, using the cache when possible.
+// The returned data must not be modified.
+func (s *SnapshotSource) decompress(block *snapshotBlock) ([]byte, error) {
+	if data := s.cached(block); data != nil {
+		return data, nil
+	}
+
+	// A new buffer every time, as evicted blocks may still be being read
+	data := make([]byte, block.rawLen)
+	decompressor := flate.NewReader(io.NewSectionReader(s.file, block.fileOffset, block.compLen))
+	defer decompressor.Close()
+	if _, err := io.ReadFull(decompressor, data); err != nil {
+		return nil, fmt.Errorf("failed to decompress snapshot block: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock++
+	entry := snapshotCacheEntry{block: block, data: data, used: s.clock}
+	oldest := 0
+	for i := range s.cache {
+		if s.cache[i].block == block {
+			// Another reader decompressed the block meanwhile
+			return s.cache[i].data, nil
+		}
+		if s.cache[i].used < s.cache[oldest].used {
+			oldest = i
+		}
+	}
+	if len(s.cache) < cap(s.cache) {
+		s.cache = append(s.cache, entry)
+	} else {
+		// Replace the least recently used block
+		s.cache[oldest] = entry
+	}
+	return data, nil
+}
+
+// cached returns the contents of the block if it is in the cache, or nil
+func (s *SnapshotSource) cached(block *snapshotBlock) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.cache {
+		if s.cache[i].block == block {
+			s.clock++
+			s.cache[i].used = s.clock
+			return s.cache[i].data
+		}
+	}
+	return nil
+}
+
+// Close closes the snapshot file
+func (s *SnapshotSource) Close() error {
+	if s.file != nil {
+		err := s.file.Close()
+		s.file = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package memoryscanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// 辅助函数：将扫描器的内存写入快照文件并打开
+func writeTestSnapshot(t *testing.T, scanner *Scanner, opts SnapshotOptions) *SnapshotSource {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.snap")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := scanner.WriteSnapshot(context.Background(), file, opts); err != nil {
+		t.Fatalf("WriteSnapshot failed: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	snapshot, err := OpenSnapshot(path)
+	if err != nil {
+		t.Fatalf("OpenSnapshot failed: %v", err)
+	}
+	return snapshot
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	source := newTestFakeSource()
+	// 跨越压缩块边界的大区域，以及中间有不可读页的区域
+	source.regions = append(source.regions, FakeRegion{
+		BaseAddress: 0x1000000,
+		Data:        makeRegionData(snapshotBlockSize+0x80000, map[int]string{snapshotBlockSize - 3: "WeChat"}),
+		Protection:  ProtectRead | ProtectWrite,
+		Type:        TypePrivate,
+	}, FakeRegion{
+		BaseAddress: 0x2000000,
+		Data:        makeRegionData(0x4000, nil),
+		Protection:  ProtectRead | ProtectWrite,
+		Type:        TypePrivate,
+		Unreadable:  []AddressRange{{Start: 0x2001000, End: 0x2003000}},
+	})
+	scanner := NewScannerFromSource(source)
+	defer scanner.Close()
+
+	snapshot := writeTestSnapshot(t, scanner, SnapshotOptions{MaxAddress: 0x7FFFFFFFFFFF})
+	snapshotScanner := NewScannerFromSource(snapshot)
+	defer snapshotScanner.Close()
+
+	original, _ := source.Regions(context.Background())
+	regions, err := snapshot.Regions(context.Background())
+	if err != nil {
+		t.Fatalf("Regions failed: %v", err)
+	}
+	if !slices.Equal(regions, original) {
+		t.Errorf("Regions() = %+v, want %+v", regions, original)
+	}
+
+	// 快照可以被多次扫描，结果与原始内存一致（不可读页中的内容除外）
+	opts := ScanOptions{
+		Pattern:    StringToPattern("WeChat", 0),
+		IgnoreCase: true,
+		MaxAddress: 0x7FFFFFFFFFFF,
+	}
+	want := []Address{0x10010, 0x10800, 0x40100, 0x1000000 + snapshotBlockSize - 3}
+	for i := 0; i < 2; i++ {
+		if result := scanAddresses(t, snapshotScanner, opts); !slices.Equal(result, want) {
+			t.Errorf("Scan #%d addresses = %v, want %v", i+1, result, want)
+		}
+	}
+
+	// 不可读页在快照中仍不可读
+	if _, err := snapshot.ReadAt(make([]byte, 16), 0x2002000); err == nil {
+		t.Error("ReadAt inside unreadable range succeeded, want error")
+	}
+	if n, err := snapshot.ReadAt(make([]byte, 0x2000), 0x2000000); err == nil || n != 0x1000 {
+		t.Errorf("ReadAt(0x2000000) = %d, %v; want 0x1000 bytes and error", n, err)
+	}
+	// 不可读页之后的页仍被保存
+	if n, err := snapshot.ReadAt(make([]byte, 0x1000), 0x2003000); err != nil || n != 0x1000 {
+		t.Errorf("ReadAt(0x2003000) = %d, %v; want 0x1000 bytes", n, err)
+	}
+	buffer := make([]byte, 6)
+	if _, err := snapshot.ReadAt(buffer, 0x10010); err != nil || string(buffer) != "WeChat" {
+		t.Errorf("ReadAt(0x10010) = %q, %v; want %q", buffer, err, "WeChat")
+	}
+}
+
+func TestSnapshotAddressRange(t *testing.T) {
+	scanner := NewScannerFromSource(newTestFakeSource())
+	defer scanner.Close()
+
+	snapshot := writeTestSnapshot(t, scanner, SnapshotOptions{MinAddress: 0x10800, MaxAddress: 0x30000})
+	defer snapshot.Close()
+
+	regions, err := snapshot.Regions(context.Background())
+	if err != nil {
+		t.Fatalf("Regions failed: %v", err)
+	}
+
+	var ranges []AddressRange
+	for _, region := range regions {
+		ranges = append(ranges, AddressRange{Start: region.BaseAddress, End: region.End()})
+	}
+	want := []AddressRange{{Start: 0x10800, End: 0x11000}, {Start: 0x20000, End: 0x21000}}
+	if !slices.Equal(ranges, want) {
+		t.Errorf("snapshot ranges = %v, want %v", ranges, want)
+	}
+}
+
+func TestSnapshotConcurrentReads(t *testing.T) {
+	// 多个 goroutine 同时读取不同的压缩块，内容与原始内存一致
+	data := makeRegionData(6*snapshotBlockSize, nil)
+	source := NewFakeSource(FakeRegion{
+		BaseAddress: 0x1000000,
+		Data:        data,
+		Protection:  ProtectRead | ProtectWrite,
+	})
+	scanner := NewScannerFromSource(source)
+	defer scanner.Close()
+
+	snapshot := writeTestSnapshot(t, scanner, SnapshotOptions{MaxAddress: 0x7FFFFFFFFFFF})
+	defer snapshot.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewPCG(uint64(worker), 0))
+			buffer := make([]byte, 0x10000)
+			for range 50 {
+				offset := rng.IntN(len(data) - len(buffer))
+				if _, err := snapshot.ReadAt(buffer, 0x1000000+Address(offset)); err != nil {
+					errs <- err
+					return
+				}
+				if !bytes.Equal(buffer, data[offset:offset+len(buffer)]) {
+					errs <- fmt.Errorf("ReadAt(%#x) returned wrong data", 0x1000000+offset)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestOpenSnapshotInvalid(t *testing.T) {
+	// 区域数为 1、名称长度为 0xFFFFFFFF 的区域表
+	longName := []byte(snapshotMagic)
+	longName = binary.LittleEndian.AppendUint32(longName, snapshotVersion)
+	longName = append(longName, make([]byte, 12)...)
+	longName = binary.LittleEndian.AppendUint32(longName, 1)
+	longName = append(longName, make([]byte, 28)...)
+	longName = binary.LittleEndian.AppendUint32(longName, 0xFFFFFFFF)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated file", []byte("MEMSNAP\x00 truncated")},
+		{"region name too long", longName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "invalid.snap")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+
+			if _, err := OpenSnapshot(path); err == nil {
+				t.Error("OpenSnapshot on an invalid file succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package memoryscanner
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// pageSize is the granularity at which failed reads are retried
+const pageSize = 0x1000
+
+// Protection describes the access rights of a memory region
+type Protection uint32
+
+const (
+	// ProtectRead means the region can be read
+	ProtectRead Protection = 1 << iota
+	// ProtectWrite means the region can be written
+	ProtectWrite
+	// ProtectExecute means the region can be executed
+	ProtectExecute
+	// ProtectCopyOnWrite means writes create a private copy of the page
+	ProtectCopyOnWrite
+	// ProtectGuard means the first access raises a guard page exception
+	ProtectGuard
+)
+
+// String returns the protection in "rwx" notation, e.g. "rw-" or "r-x+guard"
+func (p Protection) String() string {
+	var builder strings.Builder
+	for _, flag := range []struct {
+		bit  Protection
+		char byte
+	}{{ProtectRead, 'r'}, {ProtectWrite, 'w'}, {ProtectExecute, 'x'}} {
+		if p&flag.bit != 0 {
+			builder.WriteByte(flag.char)
+		} else {
+			builder.WriteByte('-')
+		}
+	}
+	if p&ProtectCopyOnWrite != 0 {
+		builder.WriteString("+cow")
+	}
+	if p&ProtectGuard != 0 {
+		builder.WriteString("+guard")
+	}
+	return builder.String()
+}
+
+// RegionState describes whether a memory region is backed by storage.
+// The zero value is StateCommit so sources that only know about mapped
+// memory do not need to set it.
+type RegionState uint32
+
+const (
+	// StateCommit means the region is backed by memory and may be read
+	StateCommit RegionState = iota
+	// StateReserve means the address range is reserved but not backed
+	StateReserve
+	// StateFree means the address range is not allocated
+	StateFree
+)
+
+// String returns the name of the state
+func (s RegionState) String() string {
+	switch s {
+	case StateCommit:
+		return "commit"
+	case StateReserve:
+		return "reserve"
+	case StateFree:
+		return "free"
+	default:
+		return "unknown"
+	}
+}
+
+// RegionType describes what kind of allocation backs a memory region.
+// The values are bit flags so several types can be combined in a mask.
+type RegionType uint32
+
+const (
+	// TypePrivate is memory private to the process (heap, stack, anonymous mappings)
+	TypePrivate RegionType = 1 << iota
+	// TypeImage is memory mapped from an executable image or shared library
+	TypeImage
+	// TypeMapped is memory mapped from a data file or shared section
+	TypeMapped
+)
+
+// String returns the name of the type
+func (t RegionType) String() string {
+	switch t {
+	case TypePrivate:
+		return "private"
+	case TypeImage:
+		return "image"
+	case TypeMapped:
+		return "mapped"
+	default:
+		return "unknown"
+	}
+}
+
+// Region describes a contiguous range of memory with uniform attributes
+type Region struct {
+	// BaseAddress is the first address of the region
+	BaseAddress Address
+	// Size is the length of the region in bytes
+	Size uint64
+	// Protection is the current access protection
+	Protection Protection
+	// State is the allocation state
+	State RegionState
+	// Type is the kind of allocation backing the region
+	Type RegionType
+	// Name is the backing module or mapped file name, empty for anonymous memory
+	Name string
+}
+
+// End returns the address just past the end of the region
+func (r Region) End() Address {
+	return r.BaseAddress + Address(r.Size)
+}
+
+// Readable reports whether the region is committed and can be read without faulting
+func (r Region) Readable() bool {
+	return r.State == StateCommit && r.Protection&ProtectRead != 0 && r.Protection&ProtectGuard == 0
+}
+
+// markImageRegions marks every mapped region whose file also has an executable
+// mapping as image memory, which is how loaded executables and libraries look
+// on platforms without a native image type
+func markImageRegions(regions []Region) {
+	images := make(map[string]bool)
+	for _, region := range regions {
+		if region.Type == TypeMapped && region.Protection&ProtectExecute != 0 {
+			images[region.Name] = true
+		}
+	}
+
+	for i := range regions {
+		if regions[i].Type == TypeMapped && images[regions[i].Name] {
+			regions[i].Type = TypeImage
+		}
+	}
+}
+
+// MemorySource provides the memory regions and contents that a Scanner searches.
+// Live processes, dump files and in-memory buffers all implement it.
+type MemorySource interface {
+	// Regions returns the memory regions of the source in ascending address order
+	Regions(ctx context.Context) ([]Region, error)
+	// ReadAt reads len(p) bytes starting at addr. It returns the number of bytes
+	// read and a non-nil error if fewer than len(p) bytes were read. ReadAt may be
+	// called from several goroutines at once when scanning with multiple workers.
+	ReadAt(p []byte, addr Address) (int, error)
+	// Close releases the resources held by the source
+	Close() error
+}
+
+// readRun is a readable stretch [start, end) of a buffer filled by readWithFallback
+type readRun struct {
+	start int
+	end   int
+}
+
+// readWithFallback reads len(buffer) bytes at addr from source. If the read fails,
+// everything after the bytes already read is retried page by page, so guard or
+// decommitted pages only lose themselves instead of the whole read. It returns
+// the readable runs of the buffer in ascending order; the gaps between them
+// could not be read. Failed reads are not errors, except when the process has
+// exited or its memory may not be read at all, so no page would be readable.
+func readWithFallback(source MemorySource, buffer []byte, addr Address) ([]readRun, error) {
+	n, err := source.ReadAt(buffer, addr)
+	if err == nil && n == len(buffer) {
+		return []readRun{{start: 0, end: len(buffer)}}, nil
+	}
+	if fatalReadError(err) {
+		return nil, err
+	}
+
+	var runs []readRun
+	if n > 0 {
+		runs = append(runs, readRun{start: 0, end: n})
+	}
+
+	for pos := n; pos < len(buffer); {
+		// Read up to the next page boundary of the original address space
+		current := uint64(addr) + uint64(pos)
+		pageEnd := min(int((current+pageSize)&^(pageSize-1)-uint64(addr)), len(buffer))
+
+		read, err := source.ReadAt(buffer[pos:pageEnd], addr+Address(pos))
+		if fatalReadError(err) {
+			return nil, err
+		}
+		if read > 0 {
+			if len(runs) > 0 && runs[len(runs)-1].end == pos {
+				runs[len(runs)-1].end = pos + read
+			} else {
+				runs = append(runs, readRun{start: pos, end: pos + read})
+			}
+		}
+		pos = pageEnd
+	}
+
+	return runs, nil
+}
+
+// fatalReadError reports whether a read error ends the scan instead of only
+// making the pages it covers unreadable
+func fatalReadError(err error) bool {
+	return errors.Is(err, ErrProcessExited) || errors.Is(err, ErrAccessDenied)
+}
@@ -0,0 +1,244 @@
+//go:build linux
+
+package memoryscanner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// processSource reads the memory of a live Linux process
+type processSource struct {
+	pid int
+	// mem is /proc/<pid>/mem, the fallback when process_vm_readv fails. memErr
+	// is the error of opening it, kept so it is not reopened for every page.
+	memMu  sync.Mutex
+	mem    *os.File
+	memErr error
+}
+
+// openProcessSource checks that the process exists and that both its memory map
+// and its memory are accessible. Reading memory needs ptrace access, which
+// ptrace_scope can deny even when the memory map is readable.
+func openProcessSource(pid uint32) (*processSource, error) {
+	mapsFile, err := os.Open(fmt.Sprintf("/proc/%d/maps", pid))
+	if err != nil {
+		return nil, processError(pid, err, false)
+	}
+	mapsFile.Close()
+
+	source := &processSource{pid: int(pid)}
+	if _, err := source.memFile(); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// processError classifies an error from opening or reading the files of process pid.
+// Once the process has been opened, a missing /proc entry means it has exited.
+func processError(pid uint32, err error, opened bool) error {
+	var kind error
+	switch {
+	case errors.Is(err, unix.ESRCH):
+		kind = ErrProcessExited
+	case errors.Is(err, fs.ErrNotExist):
+		kind = ErrProcessNotFound
+		if opened {
+			kind = ErrProcessExited
+		}
+	case errors.Is(err, fs.ErrPermission):
+		kind = ErrAccessDenied
+	default:
+		return err
+	}
+	return &ProcessError{PID: pid, Kind: kind, Err: err}
+}
+
+// Close releases the /proc/<pid>/mem handle if one was opened
+func (p *processSource) Close() error {
+	p.memMu.Lock()
+	defer p.memMu.Unlock()
+
+	if p.mem != nil {
+		err := p.mem.Close()
+		p.mem = nil
+		p.memErr = errors.New("process source is closed")
+		return err
+	}
+	return nil
+}
+
+// Regions parses /proc/<pid>/maps into regions
+func (p *processSource) Regions(ctx context.Context) ([]Region, error) {
+	mapsFile, err := os.Open(fmt.Sprintf("/proc/%d/maps", p.pid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memory map: %w", processError(uint32(p.pid), err, true))
+	}
+	defer mapsFile.Close()
+
+	var regions []Region
+
+	scanner := bufio.NewScanner(mapsFile)
+	for scanner.Scan() {
+		// Check if context was cancelled
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		region, ok := parseMapsLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		regions = append(regions, region)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read memory map: %w", err)
+	}
+
+	markImageRegions(regions)
+	return regions, nil
+}
+
+// parseMapsLine parses a /proc/<pid>/maps line such as
+// "7f1c2a000000-7f1c2a021000 rw-p 00000000 00:00 0    [heap]"
+func parseMapsLine(line string) (Region, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 || len(fields[1]) < 4 {
+		return Region{}, false
+	}
+
+	startStr, endStr, found := strings.Cut(fields[0], "-")
+	if !found {
+		return Region{}, false
+	}
+
+	start, err := strconv.ParseUint(startStr, 16, 64)
+	if err != nil {
+		return Region{}, false
+	}
+	end, err := strconv.ParseUint(endStr, 16, 64)
+	if err != nil || end <= start {
+		return Region{}, false
+	}
+
+	region := Region{
+		BaseAddress: Address(start),
+		Size:        end - start,
+		State:       StateCommit,
+		Type:        TypePrivate,
+	}
+
+	perms := fields[1]
+	if perms[0] == 'r' {
+		region.Protection |= ProtectRead
+	}
+	if perms[1] == 'w' {
+		region.Protection |= ProtectWrite
+		if perms[3] == 'p' && fields[4] != "0" {
+			region.Protection |= ProtectCopyOnWrite
+		}
+	}
+	if perms[2] == 'x' {
+		region.Protection |= ProtectExecute
+	}
+
+	if len(fields) >= 6 {
+		// The path may contain spaces, so take everything after the inode column
+		rest := line
+		for i := 0; i < 5; i++ {
+			rest = strings.TrimLeft(rest, " \t")
+			rest = rest[strings.IndexAny(rest, " \t"):]
+		}
+		region.Name = strings.TrimSpace(rest)
+		if fields[4] != "0" {
+			region.Type = TypeMapped
+		}
+	}
+
+	return region, true
+}
+
+// ReadAt reads process memory at addr into buffer, preferring process_vm_readv
+// and falling back to /proc/<pid>/mem
+func (p *processSource) ReadAt(buffer []byte, addr Address) (int, error) {
+	address := uint64(addr)
+	if len(buffer) == 0 {
+		return 0, nil
+	}
+
+	localIov := []unix.Iovec{{Base: &buffer[0]}}
+	localIov[0].SetLen(len(buffer))
+	remoteIov := []unix.RemoteIovec{{Base: uintptr(address), Len: len(buffer)}}
+
+	n, err := unix.ProcessVMReadv(p.pid, localIov, remoteIov, 0)
+	if err == nil && n == len(buffer) {
+		return n, nil
+	}
+	if errors.Is(err, unix.ESRCH) {
+		return 0, processError(uint32(p.pid), err, true)
+	}
+
+	// process_vm_readv may be blocked (seccomp, ENOSYS) or stop at the first
+	// unmapped page, while /proc/<pid>/mem returns everything up to the fault
+	mem, err := p.memFile()
+	if err != nil {
+		return max(n, 0), err
+	}
+
+	n, err = readAtAddress(mem, address, buffer)
+	if errors.Is(err, fs.ErrPermission) {
+		err = processError(uint32(p.pid), err, true)
+	}
+	return n, err
+}
+
+// memFile returns /proc/<pid>/mem, opening it on first use. ReadAt can be called
+// from several scan workers at once, so the open is guarded by memMu.
+func (p *processSource) memFile() (*os.File, error) {
+	p.memMu.Lock()
+	defer p.memMu.Unlock()
+
+	if p.mem == nil && p.memErr == nil {
+		mem, err := os.Open(fmt.Sprintf("/proc/%d/mem", p.pid))
+		if err != nil {
+			p.memErr = processError(uint32(p.pid), err, true)
+		}
+		p.mem = mem
+	}
+	return p.mem, p.memErr
+}
+
+// readAtAddress reads from /proc/<pid>/mem, whose offsets are virtual addresses.
+// Kernel addresses do not fit in a non-negative int64, so pread is called directly.
+func readAtAddress(mem *os.File, address uint64, buffer []byte) (int, error) {
+	total := 0
+	for total < len(buffer) {
+		n, err := unix.Pread(int(mem.Fd()), buffer[total:], int64(address))
+		if n > 0 {
+			total += n
+			address += uint64(n)
+		}
+		if err != nil {
+			return total, err
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	if total < len(buffer) {
+		return total, fmt.Errorf("short read at 0x%X", address)
+	}
+	return total, nil
+}
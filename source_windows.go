@@ -0,0 +1,220 @@
+//go:build windows
+
+package memoryscanner
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// GetMappedFileNameW is not wrapped by x/sys/windows
+var procGetMappedFileNameW = windows.NewLazySystemDLL("psapi.dll").NewProc("GetMappedFileNameW")
+
+// moduleRange is a loaded module and its address range
+type moduleRange struct {
+	base uint64
+	size uint64
+	name string
+}
+
+// stillActive is the exit code GetExitCodeProcess reports for a running process
+const stillActive = 259
+
+// processSource reads the memory of a live Windows process
+type processSource struct {
+	pid    uint32
+	handle windows.Handle
+}
+
+// openProcessSource opens the process with the rights needed to query and read its memory
+func openProcessSource(pid uint32) (*processSource, error) {
+	hProcess, err := windows.OpenProcess(
+		windows.PROCESS_VM_READ|windows.PROCESS_QUERY_INFORMATION,
+		false,
+		pid,
+	)
+	if err != nil {
+		return nil, processError(pid, err)
+	}
+
+	return &processSource{pid: pid, handle: hProcess}, nil
+}
+
+// processError classifies an error from opening process pid
+func processError(pid uint32, err error) error {
+	var kind error
+	switch {
+	case errors.Is(err, windows.ERROR_ACCESS_DENIED):
+		kind = ErrAccessDenied
+	case errors.Is(err, windows.ERROR_INVALID_PARAMETER):
+		// OpenProcess reports unknown process IDs as an invalid parameter
+		kind = ErrProcessNotFound
+	default:
+		return err
+	}
+	return &ProcessError{PID: pid, Kind: kind, Err: err}
+}
+
+// exited reports whether the process has exited. The handle stays valid after
+// the process exits, so failed queries and reads are checked against it.
+func (p *processSource) exited() bool {
+	var code uint32
+	return windows.GetExitCodeProcess(p.handle, &code) == nil && code != stillActive
+}
+
+// Close closes the process handle
+func (p *processSource) Close() error {
+	if p.handle != 0 {
+		windows.CloseHandle(p.handle)
+		p.handle = 0
+	}
+	return nil
+}
+
+// Regions walks the address space with VirtualQueryEx and returns every allocated region
+func (p *processSource) Regions(ctx context.Context) ([]Region, error) {
+	if p.exited() {
+		return nil, &ProcessError{PID: p.pid, Kind: ErrProcessExited, Err: windows.ERROR_PROCESS_ABORTED}
+	}
+
+	var regions []Region
+	var mbi windows.MemoryBasicInformation
+	var address uint64
+
+	for {
+		// Check if context was cancelled
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		err := windows.VirtualQueryEx(p.handle, uintptr(address), &mbi, unsafe.Sizeof(mbi))
+		if err != nil {
+			break
+		}
+
+		baseAddr := uint64(mbi.BaseAddress)
+		regionSize := uint64(mbi.RegionSize)
+
+		if mbi.State != winMemFree {
+			regions = append(regions, Region{
+				BaseAddress: Address(baseAddr),
+				Size:        regionSize,
+				Protection:  protectionFromWindows(mbi.Protect),
+				State:       stateFromWindows(mbi.State),
+				Type:        typeFromWindows(mbi.Type),
+			})
+		}
+
+		// Move to next region
+		next := baseAddr + regionSize
+		if next <= address {
+			break
+		}
+		address = next
+	}
+
+	p.nameRegions(regions)
+	return regions, nil
+}
+
+// nameRegions fills in the module path of image regions and the file path of mapped regions
+func (p *processSource) nameRegions(regions []Region) {
+	modules := p.modules()
+	for i := range regions {
+		region := &regions[i]
+		if region.Type != TypeImage && region.Type != TypeMapped {
+			continue
+		}
+
+		address := uint64(region.BaseAddress)
+		j := sort.Search(len(modules), func(j int) bool {
+			return modules[j].base+modules[j].size > address
+		})
+		if j < len(modules) && modules[j].base <= address {
+			region.Name = modules[j].name
+			continue
+		}
+
+		region.Name = p.mappedFileName(address)
+	}
+}
+
+// modules lists the loaded modules of the process sorted by base address
+func (p *processSource) modules() []moduleRange {
+	var needed uint32
+	handles := make([]windows.Handle, 256)
+	for {
+		size := uint32(len(handles)) * uint32(unsafe.Sizeof(handles[0]))
+		err := windows.EnumProcessModulesEx(p.handle, &handles[0], size, &needed, windows.LIST_MODULES_ALL)
+		if err != nil {
+			return nil
+		}
+		if needed <= size {
+			handles = handles[:needed/uint32(unsafe.Sizeof(handles[0]))]
+			break
+		}
+		handles = make([]windows.Handle, needed/uint32(unsafe.Sizeof(handles[0])))
+	}
+
+	modules := make([]moduleRange, 0, len(handles))
+	name := make([]uint16, windows.MAX_LONG_PATH)
+	for _, handle := range handles {
+		var info windows.ModuleInfo
+		if err := windows.GetModuleInformation(p.handle, handle, &info, uint32(unsafe.Sizeof(info))); err != nil {
+			continue
+		}
+		if err := windows.GetModuleFileNameEx(p.handle, handle, &name[0], uint32(len(name))); err != nil {
+			continue
+		}
+		modules = append(modules, moduleRange{
+			base: uint64(info.BaseOfDll),
+			size: uint64(info.SizeOfImage),
+			name: windows.UTF16ToString(name),
+		})
+	}
+
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].base < modules[j].base
+	})
+	return modules
+}
+
+// mappedFileName returns the device path of the file mapped at address, or "" if none
+func (p *processSource) mappedFileName(address uint64) string {
+	name := make([]uint16, windows.MAX_LONG_PATH)
+	n, _, _ := procGetMappedFileNameW.Call(uintptr(p.handle), uintptr(address),
+		uintptr(unsafe.Pointer(&name[0])), uintptr(len(name)))
+	if n == 0 {
+		return ""
+	}
+	return windows.UTF16ToString(name[:n])
+}
+
+// ReadAt reads process memory at addr into buffer
+func (p *processSource) ReadAt(buffer []byte, addr Address) (int, error) {
+	if len(buffer) == 0 {
+		return 0, nil
+	}
+
+	var bytesRead uintptr
+	err := windows.ReadProcessMemory(p.handle, uintptr(addr), &buffer[0],
+		uintptr(len(buffer)), &bytesRead)
+	if err == nil && int(bytesRead) < len(buffer) {
+		err = windows.ERROR_PARTIAL_COPY
+	}
+	switch {
+	case err != nil && bytesRead == 0 && p.exited():
+		err = &ProcessError{PID: p.pid, Kind: ErrProcessExited, Err: err}
+	case errors.Is(err, windows.ERROR_ACCESS_DENIED):
+		// Unreadable pages fail with ERROR_PARTIAL_COPY or ERROR_NOACCESS, so
+		// access denied means the handle may not read memory at all
+		err = processError(p.pid, err)
+	}
+	return int(bytesRead), err
+}
@@ -0,0 +1,53 @@
+package memoryscanner
+
+import "time"
+
+// ScanStats describes how much of the memory source a scan covered. Regions
+// outside MinAddress/MaxAddress or not listed in ScanOptions.Regions are not counted.
+type ScanStats struct {
+	// RegionsVisited is the number of regions that were searched, fully or in part
+	RegionsVisited int
+	// RegionsSkippedState counts reserved and free regions
+	RegionsSkippedState int
+	// RegionsSkippedProtection counts committed regions that are not readable or are guard pages
+	RegionsSkippedProtection int
+	// RegionsSkippedFilter counts readable regions rejected by ScanOptions.Filter
+	RegionsSkippedFilter int
+	// RegionsSkippedReadError counts selected regions of which no byte could be read
+	RegionsSkippedReadError int
+
+	// BytesScanned is the number of bytes read and searched
+	BytesScanned uint64
+	// BytesUnreadable is the number of bytes of selected regions that could not be read
+	BytesUnreadable uint64
+
+	// MatchesFound is the number of matches found, including any found by workers
+	// after the handler stopped the scan
+	MatchesFound int
+	// MatchesDelivered is the number of matches passed to the handler
+	MatchesDelivered int
+
+	// Elapsed is the duration of the scan, including enumerating regions
+	Elapsed time.Duration
+}
+
+// Throughput returns the bytes scanned per second
+func (s ScanStats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.BytesScanned) / s.Elapsed.Seconds()
+}
+
+// add adds the counts gathered by a worker
+func (s *ScanStats) add(other ScanStats) {
+	s.RegionsVisited += other.RegionsVisited
+	s.RegionsSkippedState += other.RegionsSkippedState
+	s.RegionsSkippedProtection += other.RegionsSkippedProtection
+	s.RegionsSkippedFilter += other.RegionsSkippedFilter
+	s.RegionsSkippedReadError += other.RegionsSkippedReadError
+	s.BytesScanned += other.BytesScanned
+	s.BytesUnreadable += other.BytesUnreadable
+	s.MatchesFound += other.MatchesFound
+	s.MatchesDelivered += other.MatchesDelivered
+}
@@ -0,0 +1,77 @@
+package memoryscanner
+
+import "bytes"
+
+// DefaultMaxMatchLength is the longest Match.Data can grow to when a Terminator
+// is set and ScanOptions.MaxMatchLength is not
+const DefaultMaxMatchLength = 1024
+
+// Terminator selects where a match is extended to past the end of the pattern
+type Terminator int
+
+const (
+	// TerminatorNone extends the match to MaxMatchLength bytes, or not at all if it is 0
+	TerminatorNone Terminator = iota
+	// TerminatorNUL ends the match before the first 0x00 byte
+	TerminatorNUL
+	// TerminatorUTF16NUL ends the match before the first 0x0000 code unit,
+	// counted in 2-byte steps from the start of the match
+	TerminatorUTF16NUL
+	// TerminatorNonPrintable ends the match before the first ASCII control
+	// character other than tab, newline and carriage return. Bytes of 0x80 and
+	// above are kept so UTF-8 text is not cut.
+	TerminatorNonPrintable
+)
+
+// String returns the name of the terminator
+func (t Terminator) String() string {
+	switch t {
+	case TerminatorNone:
+		return "none"
+	case TerminatorNUL:
+		return "nul"
+	case TerminatorUTF16NUL:
+		return "utf16"
+	case TerminatorNonPrintable:
+		return "print"
+	default:
+		return "unknown"
+	}
+}
+
+// maxMatchLength returns the longest match a scan can report for a pattern
+// of the given length
+func maxMatchLength(opts ScanOptions, patternLength int) int {
+	length := opts.MaxMatchLength
+	if length <= 0 {
+		if opts.Terminator == TerminatorNone {
+			return patternLength
+		}
+		length = DefaultMaxMatchLength
+	}
+	return max(length, patternLength)
+}
+
+// extend returns where a match of data[start:end] ends under the terminator,
+// at most limit
+func (t Terminator) extend(data []byte, start, end, limit int) int {
+	switch t {
+	case TerminatorNUL:
+		if i := bytes.IndexByte(data[end:limit], 0); i >= 0 {
+			return end + i
+		}
+	case TerminatorUTF16NUL:
+		for i := end + (end-start)%2; i+1 < limit; i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				return i
+			}
+		}
+	case TerminatorNonPrintable:
+		for i := end; i < limit; i++ {
+			if b := data[i]; (b < 0x20 && b != '\t' && b != '\n' && b != '\r') || b == 0x7F {
+				return i
+			}
+		}
+	}
+	return limit
+}
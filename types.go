@@ -2,7 +2,9 @@ package memoryscanner
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Address represents a memory address
@@ -13,10 +15,58 @@ func (a Address) String() string {
 	return fmt.Sprintf("0x%X", uint64(a))
 }
 
+// ParseAddress parses a hexadecimal address with an optional 0x prefix, e.g. "0x7FF6A000"
+func ParseAddress(s string) (Address, error) {
+	hexStr := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "0x"), "0X")
+	value, err := strconv.ParseUint(hexStr, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address: %s", s)
+	}
+	return Address(value), nil
+}
+
+// AddressRange represents the half-open address range [Start, End)
+type AddressRange struct {
+	Start Address
+	End   Address
+}
+
+// Size returns the number of bytes in the range
+func (r AddressRange) Size() uint64 {
+	if r.End <= r.Start {
+		return 0
+	}
+	return uint64(r.End - r.Start)
+}
+
+// String returns the range in "0xSTART-0xEND" form
+func (r AddressRange) String() string {
+	return r.Start.String() + "-" + r.End.String()
+}
+
 // Match represents a single memory match result
 type Match struct {
 	Address Address
-	Data    []byte
+	// Data holds the matched bytes, extended past the pattern according to
+	// ScanOptions.Terminator and MaxMatchLength. It is a copy that the handler may keep and is
+	// never modified by the scan; copies of several matches may share one larger
+	// allocation. With ScanOptions.ReuseMatchData it is only valid until the
+	// handler returns. Before and After follow the same rules.
+	Data []byte
+	// Before and After hold up to ScanOptions.ContextBefore and ContextAfter bytes
+	// around the match, read together with it. They are shorter near the edges of
+	// the scanned part of the region and next to unreadable pages.
+	Before []byte
+	After  []byte
+	// Patterns holds the names of the ScanOptions.Patterns matching at Address,
+	// in the order they were given. Data covers the longest of them. It is nil
+	// when scanning for ScanOptions.Pattern.
+	Patterns []string
+	// Captures holds the bytes matched by each capture group of ScanOptions.Regexp,
+	// starting with group 1, and nil for groups that did not take part in the match
+	Captures [][]byte
+	// Region is the memory region the match was found in
+	Region Region
 }
 
 // Content returns the data as a UTF-8 string, replacing invalid UTF-8 sequences
@@ -24,20 +74,98 @@ func (m Match) Content() string {
 	return strings.ToValidUTF8(string(m.Data), "")
 }
 
+// NamedPattern is a pattern of a multi-pattern scan, reported in Match.Patterns by its name
+type NamedPattern struct {
+	Name string
+	// Pattern to search for (AOB format)
+	Pattern string
+}
+
 // MatchHandler is called for each memory match found during scanning.
 // Return false to stop the scan, true to continue.
 type MatchHandler func(match Match) bool
 
+// DefaultChunkSize is the number of bytes read from a region at a time when
+// ScanOptions.ChunkSize is not set
+const DefaultChunkSize = 4 << 20
+
+// UnreadableHandler is called for each address range of a scanned region that
+// could not be read, such as guard pages or pages decommitted mid-region.
+// Adjacent unreadable pages are reported as a single range.
+type UnreadableHandler func(r AddressRange)
+
 // ScanOptions contains configuration options for memory scanning
 type ScanOptions struct {
 	// Pattern to search for (AOB format)
 	Pattern string
+	// Patterns are searched for together in a single pass over memory, instead of
+	// Pattern. A match is reported once per address however many patterns match there.
+	Patterns []NamedPattern
+	// Regexp is a regular expression in the syntax of package regexp, searched for
+	// instead of Pattern. It runs over the raw bytes with the s flag set, so .
+	// also matches newlines. Valid UTF-8 is matched character by character, and a
+	// match never starts inside a character; bytes that are not valid UTF-8 are
+	// single characters only matched by . and negated classes. Matches do not
+	// overlap and are not extended by Terminator. Matches up to MaxMatchLength
+	// bytes long, or DefaultMaxMatchLength if it is 0, are always found whole;
+	// longer ones may be cut short or missed where a chunk ends. Empty matches are
+	// not reported. The text seen by ^, \A, $ and \z starts and ends with the
+	// scanned part of the region and at unreadable pages; chunk boundaries are
+	// invisible to them, to \b and to characters crossing them.
+	Regexp string
 	// Whether to ignore case when searching text
 	IgnoreCase bool
 	// Minimum address to start scanning from (inclusive)
 	MinAddress Address
 	// Maximum address to scan to (inclusive)
 	MaxAddress Address
+	// Regions to scan, typically picked from Scanner.Regions. Nil scans every region.
+	// Unreadable regions are skipped and MinAddress/MaxAddress still apply.
+	Regions []Region
+	// Filter selects which readable regions are scanned, the zero value scans all of them
+	Filter RegionFilter
+	// ChunkSize is the number of bytes read from a region at a time, bounding the
+	// scan buffer for very large regions. 0 means DefaultChunkSize.
+	ChunkSize int
+	// Terminator extends each match past the pattern, up to MaxMatchLength bytes,
+	// until the terminator is found. The terminator itself is not part of Data.
+	Terminator Terminator
+	// MaxMatchLength is the longest Match.Data can grow to, counted from the match
+	// address. Without a Terminator, matches are extended to exactly this length
+	// where memory allows; 0 means the pattern length, or DefaultMaxMatchLength
+	// with a Terminator. Extended matches stop early at the end of the scanned
+	// part of a region or at an unreadable page instead of being dropped.
+	MaxMatchLength int
+	// ContextBefore and ContextAfter are the number of bytes captured before and
+	// after each match into Match.Before and Match.After
+	ContextBefore int
+	ContextAfter  int
+	// Workers is the number of regions read and searched concurrently, 0 or 1 scans
+	// one region at a time. Handler and UnreadableHandler are still only called
+	// from the goroutine running Scan.
+	Workers int
+	// Ordered delivers matches in ascending address order when Workers > 1.
+	// Without it matches are delivered as soon as any worker finds them.
+	Ordered bool
+	// MemoryBudget bounds the bytes of read buffers held at once across all workers.
+	// Fewer workers read at a time, and ChunkSize is reduced down to one page if a
+	// single buffer would not fit. 0 means one buffer of ChunkSize per worker.
+	MemoryBudget int
+	// ReuseMatchData lets the scan point Match.Data into its read buffers instead
+	// of copying each match. Handlers must copy Data if they keep it.
+	ReuseMatchData bool
 	// Handler called for each match found
 	Handler MatchHandler
+	// Stats, if set, receives the coverage of the scan when Scan returns, including
+	// when it returns an error or is stopped early
+	Stats *ScanStats
+	// Progress, if set, is called at most once per ProgressInterval while the scan
+	// runs and once more when it completes
+	Progress ProgressHandler
+	// ProgressInterval is the minimum time between progress reports, 0 means
+	// DefaultProgressInterval
+	ProgressInterval time.Duration
+	// UnreadableHandler, if set, is called for each range of a scanned region that
+	// could not be read and therefore was not searched
+	UnreadableHandler UnreadableHandler
 }
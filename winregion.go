@@ -0,0 +1,74 @@
+package memoryscanner
+
+// Windows memory constants, defined here so that Windows region attributes can
+// also be decoded on other platforms
+const (
+	winMemCommit  = 0x00001000
+	winMemReserve = 0x00002000
+	winMemFree    = 0x00010000
+
+	winMemPrivate = 0x00020000
+	winMemMapped  = 0x00040000
+	winMemImage   = 0x01000000
+
+	winPageNoAccess         = 0x00000001
+	winPageReadOnly         = 0x00000002
+	winPageReadWrite        = 0x00000004
+	winPageWriteCopy        = 0x00000008
+	winPageExecute          = 0x00000010
+	winPageExecuteRead      = 0x00000020
+	winPageExecuteReadWrite = 0x00000040
+	winPageExecuteWriteCopy = 0x00000080
+	winPageGuard            = 0x00000100
+)
+
+// protectionFromWindows converts a PAGE_* protection value to Protection
+func protectionFromWindows(protect uint32) Protection {
+	var p Protection
+	switch protect & 0xFF {
+	case winPageReadOnly:
+		p = ProtectRead
+	case winPageReadWrite:
+		p = ProtectRead | ProtectWrite
+	case winPageWriteCopy:
+		p = ProtectRead | ProtectWrite | ProtectCopyOnWrite
+	case winPageExecute:
+		p = ProtectExecute
+	case winPageExecuteRead:
+		p = ProtectRead | ProtectExecute
+	case winPageExecuteReadWrite:
+		p = ProtectRead | ProtectWrite | ProtectExecute
+	case winPageExecuteWriteCopy:
+		p = ProtectRead | ProtectWrite | ProtectExecute | ProtectCopyOnWrite
+	}
+	if protect&winPageGuard != 0 {
+		p |= ProtectGuard
+	}
+	return p
+}
+
+// stateFromWindows converts a MEM_COMMIT/MEM_RESERVE/MEM_FREE value to RegionState
+func stateFromWindows(state uint32) RegionState {
+	switch state {
+	case winMemCommit:
+		return StateCommit
+	case winMemReserve:
+		return StateReserve
+	default:
+		return StateFree
+	}
+}
+
+// typeFromWindows converts a MEM_PRIVATE/MEM_MAPPED/MEM_IMAGE value to RegionType
+func typeFromWindows(typ uint32) RegionType {
+	switch typ {
+	case winMemPrivate:
+		return TypePrivate
+	case winMemMapped:
+		return TypeMapped
+	case winMemImage:
+		return TypeImage
+	default:
+		return 0
+	}
+}